@@ -6,6 +6,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -24,7 +25,7 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key"}
 	config.AllowCredentials = true
 	router.Use(cors.New(config))
 
@@ -39,11 +40,28 @@ func main() {
 
 	// Transaction service routes (registering first - view the terminal when start the services)
 	router.GET("/api/wallet", createSimpleProxy(transactionServiceURL, "/wallet"))
+	router.GET("/api/wallet/reconcile", createSimpleProxy(transactionServiceURL, "/wallet/reconcile"))
+	router.GET("/api/wallet/limits", createSimpleProxy(transactionServiceURL, "/wallet/limits"))
 	router.POST("/api/wallet/add", createSimpleProxy(transactionServiceURL, "/wallet/add"))
 	router.POST("/api/wallet/withdraw", createSimpleProxy(transactionServiceURL, "/wallet/withdraw"))
 	router.POST("/api/transactions/transfer", createSimpleProxy(transactionServiceURL, "/transfer"))
 	router.GET("/api/transactions", createSimpleProxy(transactionServiceURL, "/transactions"))
 	router.GET("/api/transactions/:id", createSimpleProxy(transactionServiceURL, "/transactions"))
+	router.POST("/api/transactions/:id/accept", createPathProxy(transactionServiceURL))
+	router.POST("/api/transactions/:id/decline", createPathProxy(transactionServiceURL))
+	router.POST("/api/transactions/:id/refund", createPathProxy(transactionServiceURL))
+	router.POST("/api/transfers/batch", createPathProxy(transactionServiceURL))
+	router.GET("/api/transfers/batch/:id", createPathProxy(transactionServiceURL))
+	router.Any("/api/payouts/*path", createPathProxy(transactionServiceURL))
+	router.Any("/api/scheduled-transfers", createPathProxy(transactionServiceURL))
+	router.Any("/api/scheduled-transfers/*path", createPathProxy(transactionServiceURL))
+	router.Any("/api/payment-requests", createPathProxy(transactionServiceURL))
+	router.Any("/api/payment-requests/*path", createPathProxy(transactionServiceURL))
+	router.Any("/api/bill-splits", createPathProxy(transactionServiceURL))
+	router.Any("/api/bill-splits/*path", createPathProxy(transactionServiceURL))
+
+	// Admin routes of the transaction service, the auth service ones are under /api/auth/admin
+	router.Any("/api/admin/*path", createPathProxy(transactionServiceURL))
 
 	// Auth service routes
 	authGroup := router.Group("/api/auth")
@@ -64,6 +82,12 @@ func main() {
 
 func proxyHandler(targetURL string, basePath ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Service-to-service routes are never exposed through the gateway
+		if strings.HasPrefix(c.Param("path"), "/internal") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+
 		remote, err := url.Parse(targetURL)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Service configuration error"})
@@ -91,6 +115,17 @@ func proxyHandler(targetURL string, basePath ...string) gin.HandlerFunc {
 }
 
 func createSimpleProxy(targetURL, targetPath string) gin.HandlerFunc {
+	return createSimpleProxyFunc(targetURL, func(c *gin.Context) string {
+		// For routes with :id parameter
+		if id := c.Param("id"); id != "" {
+			return targetPath + "/" + id
+		}
+		return targetPath
+	})
+}
+
+// createSimpleProxyFunc proxies to the target service using the path returned by targetPath
+func createSimpleProxyFunc(targetURL string, targetPath func(c *gin.Context) string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		remote, err := url.Parse(targetURL)
 		if err != nil {
@@ -102,7 +137,8 @@ func createSimpleProxy(targetURL, targetPath string) gin.HandlerFunc {
 		proxy.Director = func(req *http.Request) {
 			req.URL.Scheme = remote.Scheme
 			req.URL.Host = remote.Host
-			req.URL.Path = targetPath
+			req.URL.Path = targetPath(c)
+			req.URL.RawQuery = c.Request.URL.RawQuery // pass filters and pagination through unchanged
 			req.Host = remote.Host
 
 			// Copy headers
@@ -111,17 +147,20 @@ func createSimpleProxy(targetURL, targetPath string) gin.HandlerFunc {
 					req.Header.Set(key, value)
 				}
 			}
-
-			// For routes with :id parameter
-			if id := c.Param("id"); id != "" {
-				req.URL.Path = targetPath + "/" + id
-			}
 		}
 
 		proxy.ServeHTTP(c.Writer, c.Request)
 	}
 }
 
+// createPathProxy forwards the request to the same path without the /api prefix,
+// e.g. /api/transactions/5/accept goes to /transactions/5/accept
+func createPathProxy(targetURL string) gin.HandlerFunc {
+	return createSimpleProxyFunc(targetURL, func(c *gin.Context) string {
+		return strings.TrimPrefix(c.Request.URL.Path, "/api")
+	})
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
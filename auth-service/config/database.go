@@ -47,10 +47,281 @@ func RunMigrations() {
 	);
 	`
 
+	// Handles are stored lower case so the unique constraint is case insensitive
+	addUsersHandleColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS handle VARCHAR(30) UNIQUE;
+	`
+
+	// Refresh tokens are stored hashed, rotated tokens stay in the table to detect reuse of a token family
+	createRefreshTokensTable := `
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		family_id VARCHAR(36) NOT NULL,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP,
+		replaced_by INTEGER REFERENCES refresh_tokens(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+	`
+
+	// Password reset tokens are stored hashed and can only be used once
+	createPasswordResetTokensTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_tokens (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+	`
+
+	// Verification tokens are stored hashed with their issue time and expiry.
+	// Tokens issued before this change were stored in plain text, hash them and give them a fresh lifetime.
+	addVerificationTokenExpiry := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_token_issued_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_token_expires_at TIMESTAMP;
+	UPDATE users
+	SET verification_token = encode(sha256(convert_to(verification_token, 'UTF8')), 'hex'),
+		verification_token_issued_at = NOW(),
+		verification_token_expires_at = NOW() + INTERVAL '24 hours'
+	WHERE verification_token IS NOT NULL AND verification_token_expires_at IS NULL;
+	`
+
+	// totp_secret is kept while enrollment is pending, totp_enabled is only set once a code was confirmed.
+	// totp_last_step is the last accepted time step so a code cannot be used twice.
+	addUsersTwoFactorColumns := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+	`
+
+	// Recovery codes are stored hashed and can each be used once instead of a TOTP code
+	createRecoveryCodesTable := `
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash VARCHAR(64) NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+	`
+
+	// Login challenges link the password step of a two-factor login to the code step
+	createLoginChallengesTable := `
+	CREATE TABLE IF NOT EXISTS login_challenges (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash VARCHAR(64) UNIQUE NOT NULL,
+		expires_at TIMESTAMP NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	// Lockout state of each account, the unlock token is stored hashed like the other emailed tokens
+	addUsersLockoutColumns := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS unlock_token VARCHAR(64);
+	`
+
+	// Audit trail of every login attempt, successful or not, for investigating credential stuffing
+	createLoginAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id SERIAL PRIMARY KEY,
+		email VARCHAR(255) NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		ip_address VARCHAR(45) NOT NULL,
+		user_agent TEXT,
+		success BOOLEAN NOT NULL,
+		failure_reason VARCHAR(50),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts (email, created_at);
+	CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address, created_at);
+	`
+
+	// Users get permissions through roles. The built-in roles and permissions are seeded on every start,
+	// admin always holds every permission.
+	createRolesTables := `
+	CREATE TABLE IF NOT EXISTS roles (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(50) UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS permissions (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(50) UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+		PRIMARY KEY (role_id, permission_id)
+	);
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, role_id)
+	);
+
+	INSERT INTO permissions (name, description) VALUES
+		('user:read', 'Search users and view their details'),
+		('user:verify', 'Mark a user email as verified'),
+		('user:disable', 'Disable and re-enable users'),
+		('wallet:read', 'View any wallet and its transactions'),
+		('wallet:freeze', 'Freeze and unfreeze wallets'),
+		('wallet:close', 'Close wallets for good'),
+		('wallet:adjust', 'Post manual balance adjustments'),
+		('limits:manage', 'Change limit tiers and move wallets between them'),
+		('ledger:read', 'Reconcile wallets with the ledger'),
+		('role:assign', 'Grant and revoke roles'),
+		('audit:read', 'Read the admin audit log'),
+		('payout:create', 'Upload and execute bulk payout files')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO roles (name, description) VALUES
+		('admin', 'Full access to the operations tooling'),
+		('support', 'Read-only access for customer support')
+	ON CONFLICT (name) DO NOTHING;
+
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+	WHERE r.name = 'admin'
+	ON CONFLICT DO NOTHING;
+
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT r.id, p.id FROM roles r CROSS JOIN permissions p
+	WHERE r.name = 'support' AND p.name IN ('user:read', 'wallet:read')
+	ON CONFLICT DO NOTHING;
+	`
+
+	// Admins can disable a user, every user starts out active
+	addUsersStatusColumn := `
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	`
+
+	// Every admin action on a user is recorded with the admin, the reason and what changed
+	createAuthAuditLogTable := `
+	CREATE TABLE IF NOT EXISTS auth_audit_log (
+		id SERIAL PRIMARY KEY,
+		admin_user_id INTEGER NOT NULL REFERENCES users(id),
+		action VARCHAR(50) NOT NULL,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		reason TEXT NOT NULL DEFAULT '',
+		details JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_auth_audit_log_user ON auth_audit_log (user_id, created_at DESC);
+	`
+
+	// A user is either active or disabled by an admin
+	addUsersStatusCheck := `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_status_check') THEN
+			ALTER TABLE users ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'disabled'));
+		END IF;
+	END $$;
+	`
+
+	// Every accepted forgot password request, registered email or not, to limit how often reset emails can be requested
+	createPasswordResetRequestsTable := `
+	CREATE TABLE IF NOT EXISTS password_reset_requests (
+		id SERIAL PRIMARY KEY,
+		email VARCHAR(255) NOT NULL,
+		ip_address VARCHAR(45) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_requests_email ON password_reset_requests (email, created_at);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_requests_ip_address ON password_reset_requests (ip_address, created_at);
+	`
+
 	_, err := DB.Exec(createUsersTable)
 	if err != nil {
 		log.Fatal("Failed to create users table:", err)
 	}
 
+	_, err = DB.Exec(addUsersHandleColumn)
+	if err != nil {
+		log.Fatal("Failed to add handle column to users table:", err)
+	}
+
+	_, err = DB.Exec(addVerificationTokenExpiry)
+	if err != nil {
+		log.Fatal("Failed to add verification token expiry to users table:", err)
+	}
+
+	_, err = DB.Exec(createRefreshTokensTable)
+	if err != nil {
+		log.Fatal("Failed to create refresh_tokens table:", err)
+	}
+
+	_, err = DB.Exec(createPasswordResetTokensTable)
+	if err != nil {
+		log.Fatal("Failed to create password_reset_tokens table:", err)
+	}
+
+	_, err = DB.Exec(addUsersTwoFactorColumns)
+	if err != nil {
+		log.Fatal("Failed to add two-factor columns to users table:", err)
+	}
+
+	_, err = DB.Exec(createRecoveryCodesTable)
+	if err != nil {
+		log.Fatal("Failed to create recovery_codes table:", err)
+	}
+
+	_, err = DB.Exec(createLoginChallengesTable)
+	if err != nil {
+		log.Fatal("Failed to create login_challenges table:", err)
+	}
+
+	_, err = DB.Exec(addUsersLockoutColumns)
+	if err != nil {
+		log.Fatal("Failed to add lockout columns to users table:", err)
+	}
+
+	_, err = DB.Exec(createLoginAttemptsTable)
+	if err != nil {
+		log.Fatal("Failed to create login_attempts table:", err)
+	}
+
+	_, err = DB.Exec(createRolesTables)
+	if err != nil {
+		log.Fatal("Failed to create roles tables:", err)
+	}
+
+	_, err = DB.Exec(addUsersStatusColumn)
+	if err != nil {
+		log.Fatal("Failed to add status column to users table:", err)
+	}
+
+	_, err = DB.Exec(addUsersStatusCheck)
+	if err != nil {
+		log.Fatal("Failed to add status check to users table:", err)
+	}
+
+	_, err = DB.Exec(createAuthAuditLogTable)
+	if err != nil {
+		log.Fatal("Failed to create auth_audit_log table:", err)
+	}
+
+	_, err = DB.Exec(createPasswordResetRequestsTable)
+	if err != nil {
+		log.Fatal("Failed to create password_reset_requests table:", err)
+	}
+
 	log.Println("Database migrations completed successfully")
 }
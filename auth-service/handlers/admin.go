@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User statuses
+const (
+	userActive   = "active"
+	userDisabled = "disabled"
+)
+
+const accountDisabledMessage = "This account has been disabled, please contact support"
+
+// Actions recorded in auth_audit_log
+const (
+	auditUserVerified = "user_verified"
+	auditUserDisabled = "user_disabled"
+	auditUserEnabled  = "user_enabled"
+	auditRoleAssigned = "role_assigned"
+	auditRoleRemoved  = "role_removed"
+)
+
+const (
+	defaultAdminPageLimit = 50
+	maxAdminPageLimit     = 200
+)
+
+// recordAdminAction writes an entry to the auth audit log, in the same transaction as the action when q is one
+func recordAdminAction(q queryer, adminID interface{}, action string, userID int, reason string, details gin.H) error {
+	if details == nil {
+		details = gin.H{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO auth_audit_log (admin_user_id, action, user_id, reason, details) VALUES ($1, $2, $3, $4, $5)",
+		adminID, action, userID, reason, detailsJSON,
+	)
+	return err
+}
+
+// SearchUsers finds users by name, email or handle.
+// Query parameters: q, status, limit, offset
+func SearchUsers(c *gin.Context) {
+	limit, offset, ok := parseAdminPage(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != userActive && status != userDisabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active or disabled"})
+		return
+	}
+
+	// Escape LIKE wildcards so the search term is matched literally
+	search := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSpace(c.Query("q")))
+
+	rows, err := config.DB.Query(
+		`SELECT id, name, email, COALESCE(handle, ''), is_verified, status, totp_enabled, created_at, updated_at
+		 FROM users
+		 WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%' OR handle ILIKE '%' || $1 || '%')
+		   AND ($2 = '' OR status = $2)
+		 ORDER BY id
+		 LIMIT $3 OFFSET $4`,
+		search, status, limit, offset,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Handle, &user.IsVerified, &user.Status, &user.TwoFactorEnabled, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+			return
+		}
+		users = append(users, user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "limit": limit, "offset": offset})
+}
+
+// AdminGetUser shows a user with their roles and lockout state
+func AdminGetUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var detail models.AdminUserDetail
+	err = config.DB.QueryRow(
+		`SELECT id, name, email, COALESCE(handle, ''), is_verified, status, totp_enabled, created_at, updated_at,
+		        failed_login_attempts, CASE WHEN locked_until > NOW() THEN locked_until END
+		 FROM users WHERE id = $1`,
+		userID,
+	).Scan(&detail.ID, &detail.Name, &detail.Email, &detail.Handle, &detail.IsVerified, &detail.Status, &detail.TwoFactorEnabled,
+		&detail.CreatedAt, &detail.UpdatedAt, &detail.FailedLoginAttempts, &detail.LockedUntil)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	detail.Roles, _, err = loadUserAccess(config.DB, detail.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// AdminVerifyUser marks a user's email as verified, for users who cannot receive the verification email
+func AdminVerifyUser(c *gin.Context) {
+	updateUserAsAdmin(c, auditUserVerified,
+		`UPDATE users SET is_verified = true, verification_token = NULL, verification_token_expires_at = NULL, updated_at = NOW()
+		 WHERE id = $1 AND is_verified = false`,
+		"User is already verified", "User verified successfully",
+	)
+}
+
+// DisableUser blocks a user and ends all of their sessions
+func DisableUser(c *gin.Context) {
+	updateUserAsAdmin(c, auditUserDisabled,
+		"UPDATE users SET status = 'disabled', updated_at = NOW() WHERE id = $1 AND status <> 'disabled'",
+		"User is already disabled", "User disabled successfully",
+	)
+}
+
+// EnableUser lets a disabled user log in again
+func EnableUser(c *gin.Context) {
+	updateUserAsAdmin(c, auditUserEnabled,
+		"UPDATE users SET status = 'active', updated_at = NOW() WHERE id = $1 AND status = 'disabled'",
+		"User is not disabled", "User enabled successfully",
+	)
+}
+
+// updateUserAsAdmin runs an admin update on a user and records it in the audit log in one transaction.
+// The update matches no row when the user is already in the target state, which is reported with unchangedMessage.
+func updateUserAsAdmin(c *gin.Context, action, update, unchangedMessage, message string) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req models.AdminActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id")
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var userExists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&userExists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !userExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	result, err := tx.Exec(update, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": unchangedMessage})
+		return
+	}
+
+	// A disabled user must not keep using the sessions they already have
+	if action == auditUserDisabled {
+		if err = revokeAllSessions(tx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	}
+
+	if err = recordAdminAction(tx, adminID, action, userID, req.Reason, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	log.Printf("Admin %v: %s for user %d: %s", adminID, action, userID, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// GetAuditLog lists the newest admin actions on users, optionally for one user.
+// Query parameters: user_id, action, limit, offset
+func GetAuditLog(c *gin.Context) {
+	limit, offset, ok := parseAdminPage(c)
+	if !ok {
+		return
+	}
+
+	userID := 0
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a positive number"})
+			return
+		}
+		userID = id
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT id, admin_user_id, action, user_id, reason, details, created_at
+		 FROM auth_audit_log
+		 WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR action = $2)
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $3 OFFSET $4`,
+		userID, c.Query("action"), limit, offset,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLogEntry{}
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.AdminUserID, &entry.Action, &entry.UserID, &entry.Reason, &details, &entry.CreatedAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+			return
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// parseAdminPage reads the limit and offset query parameters of the admin list endpoints
+func parseAdminPage(c *gin.Context) (int, int, bool) {
+	limit, offset := defaultAdminPageLimit, 0
+
+	if value := c.Query("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > maxAdminPageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and " + strconv.Itoa(maxAdminPageLimit)})
+			return 0, 0, false
+		}
+		limit = l
+	}
+
+	if value := c.Query("offset"); value != "" {
+		o, err := strconv.Atoi(value)
+		if err != nil || o < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a number of at least 0"})
+			return 0, 0, false
+		}
+		offset = o
+	}
+
+	return limit, offset, true
+}
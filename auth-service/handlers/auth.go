@@ -5,10 +5,11 @@ import (
 	"auth-service/models"
 	"auth-service/utils"
 	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Register a new user
@@ -27,6 +28,26 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Handle is optional at registration but must be valid and free when given
+	if req.Handle != "" {
+		req.Handle, err = utils.NormalizeHandle(req.Handle)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var handleTaken bool
+		err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE handle = $1)", req.Handle).Scan(&handleTaken)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if handleTaken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Handle is already taken"})
+			return
+		}
+	}
+
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -34,23 +55,33 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Generate verification token
-	verificationToken := uuid.New().String()
+	// Generate verification token, only its hash is stored
+	verificationToken, err := utils.GenerateSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
+	}
+	validFor := utils.VerificationTokenTTL()
 
 	// Insert user
 	var userID int
 	err = config.DB.QueryRow(
-		"INSERT INTO users (name, email, password, verification_token, is_verified) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		req.Name, req.Email, hashedPassword, verificationToken, false,
+		`INSERT INTO users (name, email, password, verification_token, verification_token_issued_at, verification_token_expires_at, is_verified, handle)
+		 VALUES ($1, $2, $3, $4, NOW(), NOW() + make_interval(hours => $5), $6, NULLIF($7, '')) RETURNING id`,
+		req.Name, req.Email, hashedPassword, utils.HashToken(verificationToken), int(validFor.Hours()), false, req.Handle,
 	).Scan(&userID)
 
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User with this email or handle already exists"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
 	// Send verification email
-	go utils.SendVerificationEmail(req.Email, verificationToken)
+	go utils.SendVerificationEmail(req.Email, verificationToken, validFor)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "User registered successfully. Please check your email to verify your account.",
@@ -65,15 +96,33 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email filtered from the users table
+	// Throttle addresses that fail across many accounts, the pattern of credential stuffing
+	retryAfter, err := ipRetryAfter(c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if retryAfter > 0 {
+		recordLoginAttempt(c, req.Email, 0, loginFailureIPThrottled)
+		respondLoginThrottled(c, http.StatusTooManyRequests, "Too many failed login attempts, please try again later", retryAfter)
+		return
+	}
+
+	// Find user by email filtered from the users table, along with the lockout state measured by the database clock
 	var user models.User
-	err := config.DB.QueryRow(
-		"SELECT id, name, email, password, is_verified FROM users WHERE email = $1",
+	var failedAttempts int
+	var secondsSinceFailure, lockedSeconds float64
+	err = config.DB.QueryRow(
+		`SELECT id, name, email, password, is_verified, status, totp_enabled, failed_login_attempts,
+			COALESCE(EXTRACT(EPOCH FROM NOW() - last_failed_login_at), 0),
+			COALESCE(EXTRACT(EPOCH FROM locked_until - NOW()), 0)
+		 FROM users WHERE email = $1`,
 		req.Email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.IsVerified)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.IsVerified, &user.Status, &user.TwoFactorEnabled, &failedAttempts, &secondsSinceFailure, &lockedSeconds)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
+			recordLoginAttempt(c, req.Email, 0, loginFailureUnknownEmail)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
@@ -81,29 +130,76 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Locked accounts are rejected before the password is even checked
+	if lockedSeconds > 0 {
+		recordLoginAttempt(c, req.Email, user.ID, loginFailureLocked)
+		respondLoginThrottled(c, http.StatusLocked, "Account is temporarily locked after too many failed login attempts. Check your email for an unlock link or try again later.", secondsToDuration(lockedSeconds))
+		return
+	}
+
+	// Every consecutive failure doubles the wait before the next attempt
+	if wait := utils.LoginDelay(failedAttempts) - secondsToDuration(secondsSinceFailure); failedAttempts > 0 && wait > 0 {
+		recordLoginAttempt(c, req.Email, user.ID, loginFailureThrottled)
+		respondLoginThrottled(c, http.StatusTooManyRequests, "Too many failed login attempts, please wait before trying again", wait)
+		return
+	}
+
 	// Check if email is verified
 	if !user.IsVerified {
+		recordLoginAttempt(c, req.Email, user.ID, loginFailureUnverified)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Please verify your email before logging in"})
 		return
 	}
 
 	// Verify password
 	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		recordLoginAttempt(c, req.Email, user.ID, loginFailureWrongPassword)
+		locked, err := registerFailedLogin(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if locked {
+			respondLoginThrottled(c, http.StatusLocked, "Too many failed login attempts, the account is temporarily locked. We emailed you a link to unlock it.", utils.LoginLockoutDuration())
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	// Only told after the right password, so the status of an account is not revealed to anyone guessing
+	if user.Status == userDisabled {
+		recordLoginAttempt(c, req.Email, user.ID, loginFailureDisabled)
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+
+	// With two-factor authentication the password only earns a challenge, tokens are issued by LoginTwoFactor.
+	// Failed logins are only cleared once the code is right, so wrong codes count towards the lockout.
+	if user.TwoFactorEnabled {
+		challenge, err := createLoginChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	recordLoginAttempt(c, req.Email, user.ID, "")
+	if err = clearFailedLogins(config.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Generate the access token and start a new refresh token family
+	response, _, err := issueSession(config.DB, user, "")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 func VerifyEmail(c *gin.Context) {
@@ -113,21 +209,39 @@ func VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	// Update user verification status
-	result, err := config.DB.Exec(
-		"UPDATE users SET is_verified = true, verification_token = null WHERE verification_token = $1",
-		token,
-	)
+	// Expiry is checked by the database clock that set it, the column has no time zone
+	var userID int
+	var expired bool
+	err := config.DB.QueryRow(
+		"SELECT id, COALESCE(verification_token_expires_at <= NOW(), true) FROM users WHERE verification_token = $1",
+		utils.HashToken(token),
+	).Scan(&userID, &expired)
 
+	//If the user is not found
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification token"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
 		return
 	}
 
-	//If the user is not found
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+	// Expired links get their own status so the client can offer to send a new one
+	if expired {
+		c.JSON(http.StatusGone, gin.H{"error": "Verification link has expired, please request a new verification email"})
+		return
+	}
+
+	// Update user verification status
+	_, err = config.DB.Exec(
+		`UPDATE users
+		 SET is_verified = true, verification_token = null, verification_token_issued_at = null, verification_token_expires_at = null
+		 WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
 		return
 	}
 
@@ -144,9 +258,9 @@ func SendVerificationEmail(c *gin.Context) {
 	// Find user and check if already verified
 	var user models.User
 	err := config.DB.QueryRow(
-		"SELECT id, is_verified, verification_token FROM users WHERE email = $1",
+		"SELECT id, is_verified FROM users WHERE email = $1",
 		req.Email,
-	).Scan(&user.ID, &user.IsVerified, &user.VerificationToken)
+	).Scan(&user.ID, &user.IsVerified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -162,21 +276,28 @@ func SendVerificationEmail(c *gin.Context) {
 		return
 	}
 
-	// Generate new verification token if needed
-	if user.VerificationToken == "" {
-		user.VerificationToken = uuid.New().String()
-		_, err = config.DB.Exec(
-			"UPDATE users SET verification_token = $1 WHERE id = $2",
-			user.VerificationToken, user.ID,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
-			return
-		}
+	// Only the hash of the previous token is stored so it cannot be sent again.
+	// Issue a new token with a fresh lifetime, which also replaces a stale or expired one.
+	verificationToken, err := utils.GenerateSecureToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
+	}
+	validFor := utils.VerificationTokenTTL()
+
+	_, err = config.DB.Exec(
+		`UPDATE users
+		 SET verification_token = $1, verification_token_issued_at = NOW(), verification_token_expires_at = NOW() + make_interval(hours => $2)
+		 WHERE id = $3`,
+		utils.HashToken(verificationToken), int(validFor.Hours()), user.ID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
 	}
 
 	// Send verification email
-	go utils.SendVerificationEmail(req.Email, user.VerificationToken)
+	go utils.SendVerificationEmail(req.Email, verificationToken, validFor)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent successfully"})
 }
@@ -191,44 +312,106 @@ func GetCurrentUser(c *gin.Context) {
 
 	var user models.User
 	err := config.DB.QueryRow(
-		"SELECT id, name, email, is_verified, created_at FROM users WHERE id = $1",
+		"SELECT id, name, email, COALESCE(handle, ''), is_verified, totp_enabled, created_at FROM users WHERE id = $1",
 		userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.IsVerified, &user.CreatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Handle, &user.IsVerified, &user.TwoFactorEnabled, &user.CreatedAt)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	user.Roles, _, err = loadUserAccess(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
-// Fetching all verified users details
-func GetAllUsers(c *gin.Context) {
+// Sets or changes the handle of the current user
+func UpdateHandle(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	rows, err := config.DB.Query(
-		"SELECT id, name, email FROM users WHERE id != $1 AND is_verified = true",
-		userID,
+	var req models.UpdateHandleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	handle, err := utils.NormalizeHandle(req.Handle)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err = config.DB.Exec(
+		"UPDATE users SET handle = $1, updated_at = NOW() WHERE id = $2",
+		handle, userID,
 	)
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Handle is already taken"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update handle"})
 		return
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
-			continue
+	c.JSON(http.StatusOK, gin.H{"message": "Handle updated successfully", "handle": handle})
+}
+
+// Fetching a user for other services, used by the transaction-service to validate transfer receivers
+func GetUserByID(c *gin.Context) {
+	findUser(c, "id = $1", c.Param("id"))
+}
+
+// Finding a user by email or handle for other services, used to resolve transfer receivers
+func LookupUser(c *gin.Context) {
+	if email := c.Query("email"); email != "" {
+		findUser(c, "LOWER(email) = LOWER($1)", email)
+		return
+	}
+
+	if handle := c.Query("handle"); handle != "" {
+		normalized, err := utils.NormalizeHandle(handle)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
 		}
-		users = append(users, user)
+		findUser(c, "handle = $1", normalized)
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "email or handle query parameter is required"})
+}
+
+func findUser(c *gin.Context, condition string, value interface{}) {
+	var user models.User
+	err := config.DB.QueryRow(
+		"SELECT id, name, email, COALESCE(handle, ''), is_verified, status, created_at FROM users WHERE "+condition,
+		value,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Handle, &user.IsVerified, &user.Status, &user.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
 
-	c.JSON(http.StatusOK, users)
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
 }
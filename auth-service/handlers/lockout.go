@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/utils"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons stored in login_attempts.failure_reason
+const (
+	loginFailureUnknownEmail  = "unknown_email"
+	loginFailureWrongPassword = "wrong_password"
+	loginFailureWrongCode     = "wrong_code"
+	loginFailureUnverified    = "unverified"
+	loginFailureLocked        = "locked"
+	loginFailureThrottled     = "throttled"
+	loginFailureIPThrottled   = "ip_throttled"
+	loginFailureDisabled      = "disabled"
+)
+
+// recordLoginAttempt writes the audit row of a login attempt, an empty failureReason means it succeeded.
+// A failure to record is logged but never blocks the login itself.
+func recordLoginAttempt(c *gin.Context, email string, userID int, failureReason string) {
+	_, err := config.DB.Exec(
+		`INSERT INTO login_attempts (email, user_id, ip_address, user_agent, success, failure_reason)
+		 VALUES (LOWER($1), NULLIF($2, 0), $3, $4, $5, NULLIF($6, ''))`,
+		email, userID, c.ClientIP(), c.Request.UserAgent(), failureReason == "", failureReason,
+	)
+	if err != nil {
+		log.Printf("Failed to record login attempt for %s: %v", email, err)
+	}
+}
+
+// ipRetryAfter reports how long an IP address has to wait after too many failed logins across any accounts.
+// Rejected attempts are not counted, so waiting out the window is enough to get through again.
+func ipRetryAfter(ip string) (time.Duration, error) {
+	var failures int
+	var retryAfterSeconds float64
+	err := config.DB.QueryRow(
+		`SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(created_at) + make_interval(mins => $2) - NOW()), 0)
+		 FROM (
+			SELECT created_at FROM login_attempts
+			WHERE ip_address = $1 AND NOT success
+			  AND failure_reason NOT IN ('locked', 'throttled', 'ip_throttled')
+			  AND created_at > NOW() - make_interval(mins => $2)
+			ORDER BY created_at DESC
+			LIMIT $3
+		 ) recent`,
+		ip, int(utils.LoginIPWindow().Minutes()), utils.LoginMaxFailedAttemptsPerIP(),
+	).Scan(&failures, &retryAfterSeconds)
+	if err != nil || failures < utils.LoginMaxFailedAttemptsPerIP() {
+		return 0, err
+	}
+	return secondsToDuration(retryAfterSeconds), nil
+}
+
+// registerFailedLogin counts a wrong password or two-factor code against the account and locks it once the limit is reached.
+// Locking starts the count over, so the progressive delay is short again once the lock ends.
+func registerFailedLogin(user models.User) (bool, error) {
+	unlockToken, err := utils.GenerateSecureToken()
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	err = config.DB.QueryRow(
+		`UPDATE users SET
+			failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2 THEN 0 ELSE failed_login_attempts + 1 END,
+			last_failed_login_at = NOW(),
+			locked_until = CASE WHEN failed_login_attempts + 1 >= $2 THEN NOW() + make_interval(mins => $3) ELSE locked_until END,
+			unlock_token = CASE WHEN failed_login_attempts + 1 >= $2 THEN $4 ELSE unlock_token END
+		 WHERE id = $1
+		 RETURNING COALESCE(locked_until > NOW(), false)`,
+		user.ID, utils.LoginMaxFailedAttempts(), int(utils.LoginLockoutDuration().Minutes()), utils.HashToken(unlockToken),
+	).Scan(&locked)
+	if err != nil {
+		return false, err
+	}
+
+	if locked {
+		log.Printf("Account of user %d locked after %d failed login attempts", user.ID, utils.LoginMaxFailedAttempts())
+		go utils.SendAccountUnlockEmail(user.Email, unlockToken, utils.LoginLockoutDuration())
+	}
+	return locked, nil
+}
+
+// clearFailedLogins resets the lockout state after a successful login
+func clearFailedLogins(q queryer, userID int) error {
+	_, err := q.Exec(
+		`UPDATE users SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, unlock_token = NULL
+		 WHERE id = $1 AND (failed_login_attempts > 0 OR locked_until IS NOT NULL)`,
+		userID,
+	)
+	return err
+}
+
+// respondLoginThrottled answers a rejected login with the time to wait in the Retry-After header and the body
+func respondLoginThrottled(c *gin.Context, status int, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(status, gin.H{"error": message, "retry_after": seconds})
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Unlocks an account with the token from the lockout email
+func UnlockAccount(c *gin.Context) {
+	var req models.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID int
+	err := config.DB.QueryRow(
+		`UPDATE users SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, unlock_token = NULL
+		 WHERE unlock_token = $1
+		 RETURNING id`,
+		utils.HashToken(req.Token),
+	).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or already used unlock link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	log.Printf("Account of user %d unlocked by email", userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked, you can log in again"})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/utils"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Same answer whether or not the email is registered, so the endpoint cannot be used to find accounts
+const forgotPasswordMessage = "If an account exists for this email, a password reset link has been sent"
+
+// Emails a single use password reset link. The account is looked up after the response, so registered and
+// unknown emails are answered in the same time.
+func ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	retryAfter, err := passwordResetRetryAfter(email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if retryAfter > 0 {
+		respondLoginThrottled(c, http.StatusTooManyRequests, "Too many password reset requests, please try again later", retryAfter)
+		return
+	}
+
+	_, err = config.DB.Exec(
+		"INSERT INTO password_reset_requests (email, ip_address) VALUES ($1, $2)",
+		email, c.ClientIP(),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	go sendPasswordReset(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": forgotPasswordMessage})
+}
+
+// passwordResetRetryAfter reports how long to wait before another reset email can be requested for the email or from
+// the IP address. Unknown emails count the same as registered ones, so the limit does not reveal which are registered.
+func passwordResetRetryAfter(email, ip string) (time.Duration, error) {
+	limits := []struct {
+		column, value string
+		max           int
+	}{
+		{"email", email, utils.PasswordResetMaxPerEmail()},
+		{"ip_address", ip, utils.PasswordResetMaxPerIP()},
+	}
+
+	var retryAfter time.Duration
+	for _, limit := range limits {
+		var requests int
+		var retryAfterSeconds float64
+		err := config.DB.QueryRow(
+			`SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(created_at) + make_interval(mins => $2) - NOW()), 0)
+			 FROM (
+				SELECT created_at FROM password_reset_requests
+				WHERE `+limit.column+` = $1 AND created_at > NOW() - make_interval(mins => $2)
+				ORDER BY created_at DESC
+				LIMIT $3
+			 ) recent`,
+			limit.value, int(utils.PasswordResetWindow().Minutes()), limit.max,
+		).Scan(&requests, &retryAfterSeconds)
+		if err != nil {
+			return 0, err
+		}
+		if wait := secondsToDuration(retryAfterSeconds); requests >= limit.max && wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+// sendPasswordReset issues a reset token for the account of the email, if there is one, and emails the link.
+// Only the most recent link works, earlier unused links are invalidated.
+func sendPasswordReset(email string) {
+	var userID int
+	err := config.DB.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up account for password reset: %v", err)
+		return
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		log.Printf("Failed to generate password reset token for user %d: %v", userID, err)
+		return
+	}
+
+	validFor := utils.PasswordResetTTL()
+	_, err = config.DB.Exec(
+		"UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	)
+	if err == nil {
+		_, err = config.DB.Exec(
+			"INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, NOW() + make_interval(mins => $3))",
+			userID, utils.HashToken(token), int(validFor.Minutes()),
+		)
+	}
+	if err != nil {
+		log.Printf("Failed to store password reset token for user %d: %v", userID, err)
+		return
+	}
+
+	utils.SendPasswordResetEmail(email, token, validFor)
+}
+
+// Sets a new password with a token from ForgotPassword, lifts any lockout and logs the user out of every session
+func ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the token so two requests with the same token cannot both use it. Expiry is checked by the database clock,
+	// the column has no time zone.
+	var tokenID, userID int
+	var expired bool
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, user_id, expires_at <= NOW(), used_at FROM password_reset_tokens WHERE token_hash = $1 FOR UPDATE",
+		utils.HashToken(req.Token),
+	).Scan(&tokenID, &userID, &expired, &usedAt)
+
+	if err == sql.ErrNoRows || (err == nil && (usedAt.Valid || expired)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	_, err = tx.Exec(
+		`UPDATE users
+		 SET password = $1, failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL, unlock_token = NULL, updated_at = NOW()
+		 WHERE id = $2`,
+		hashedPassword, userID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE id = $1", tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Whoever knew the old password may still hold a session, end them all
+	if err = revokeAllSessions(tx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	log.Printf("Password reset for user %d, all sessions revoked", userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in with your new password"})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const adminRole = "admin"
+
+// loadUserAccess returns the names of the user's roles and of every permission those roles grant
+func loadUserAccess(q queryer, userID int) ([]string, []string, error) {
+	roles, err := queryStrings(q,
+		`SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		 WHERE ur.user_id = $1 ORDER BY r.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	permissions, err := queryStrings(q,
+		`SELECT DISTINCT p.name FROM user_roles ur
+		 JOIN role_permissions rp ON rp.role_id = ur.role_id
+		 JOIN permissions p ON p.id = rp.permission_id
+		 WHERE ur.user_id = $1 ORDER BY p.name`,
+		userID,
+	)
+	return roles, permissions, err
+}
+
+func queryStrings(q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// Lists every role with its permissions
+func ListRoles(c *gin.Context) {
+	rows, err := config.DB.Query(
+		`SELECT r.id, r.name, r.description, COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		 FROM roles r
+		 LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		 LEFT JOIN permissions p ON p.id = rp.permission_id
+		 GROUP BY r.id
+		 ORDER BY r.name`,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, pq.Array(&role.Permissions)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// Grants a role to a user. The new permissions are part of the user's next access token.
+func AssignRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = assignRole(tx, userID, req.Role)
+	if err == nil {
+		err = recordAdminAction(tx, c.MustGet("user_id"), auditRoleAssigned, userID, req.Reason, gin.H{"role": req.Role})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if errors.Is(err, errRoleNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role " + req.Role})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+
+	log.Printf("User %v granted role %s to user %d", c.MustGet("user_id"), req.Role, userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+// Revokes a role from a user, the last admin cannot be removed. An optional ?reason= is kept in the audit log.
+func RemoveRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	role := c.Param("role")
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if role == adminRole {
+		// Lock the admin role so two admins cannot remove each other at the same time
+		var adminRoleID, admins int
+		err = tx.QueryRow("SELECT id FROM roles WHERE name = $1 FOR UPDATE", adminRole).Scan(&adminRoleID)
+		if err == nil {
+			err = tx.QueryRow("SELECT COUNT(*) FROM user_roles WHERE role_id = $1", adminRoleID).Scan(&admins)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if admins <= 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last admin"})
+			return
+		}
+	}
+
+	result, err := tx.Exec(
+		"DELETE FROM user_roles WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)",
+		userID, role,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User does not have this role"})
+		return
+	}
+
+	if err = recordAdminAction(tx, c.MustGet("user_id"), auditRoleRemoved, userID, c.Query("reason"), gin.H{"role": role}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	log.Printf("User %v removed role %s from user %d", c.MustGet("user_id"), role, userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
+
+var errRoleNotFound = errors.New("role not found")
+
+// assignRole grants a role by name, granting a role the user already has is not an error
+func assignRole(q queryer, userID int, role string) error {
+	var roleID int
+	err := q.QueryRow("SELECT id FROM roles WHERE name = $1", role).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return errRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var userExists bool
+	if err = q.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&userExists); err != nil {
+		return err
+	}
+	if !userExists {
+		return sql.ErrNoRows
+	}
+
+	_, err = q.Exec(
+		"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID, roleID,
+	)
+	return err
+}
+
+// BootstrapAdmin grants the admin role to the user with the given email.
+// It is run from the command line to create the first admin, later admins are granted through the API.
+func BootstrapAdmin(email string) error {
+	var userID int
+	err := config.DB.QueryRow("SELECT id FROM users WHERE email = $1", email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user registered with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = assignRole(config.DB, userID, adminRole); err != nil {
+		return err
+	}
+
+	log.Printf("Granted the %s role to %s (user %d)", adminRole, email, userID)
+	return nil
+}
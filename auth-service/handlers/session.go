@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/utils"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// issueSession creates an access token and a refresh token for the user.
+// An empty familyID starts a new token family, i.e. a new login session.
+// The user's current roles and permissions are embedded in the access token.
+func issueSession(q queryer, user models.User, familyID string) (models.LoginResponse, int, error) {
+	roles, permissions, err := loadUserAccess(q, user.ID)
+	if err != nil {
+		return models.LoginResponse{}, 0, err
+	}
+	user.Roles = roles
+
+	accessToken, err := utils.GenerateJWT(user.ID, user.Email, roles, permissions)
+	if err != nil {
+		return models.LoginResponse{}, 0, err
+	}
+
+	refreshToken, err := utils.GenerateSecureToken()
+	if err != nil {
+		return models.LoginResponse{}, 0, err
+	}
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	var refreshTokenID int
+	err = q.QueryRow(
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, NOW() + make_interval(secs => $4)) RETURNING id",
+		user.ID, familyID, utils.HashToken(refreshToken), int(utils.RefreshTokenTTL().Seconds()),
+	).Scan(&refreshTokenID)
+	if err != nil {
+		return models.LoginResponse{}, 0, err
+	}
+
+	return models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+		User:         user,
+	}, refreshTokenID, nil
+}
+
+// revokeTokenFamily ends a login session by revoking every refresh token issued in it
+func revokeTokenFamily(q queryer, familyID string) error {
+	_, err := q.Exec(
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID,
+	)
+	return err
+}
+
+// revokeAllSessions logs a user out everywhere, e.g. after a password change
+func revokeAllSessions(q queryer, userID int) error {
+	_, err := q.Exec(
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	)
+	return err
+}
+
+// Publishes the public signing keys. Verifiers cache the document, so keys stay listed during a rotation.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
+
+// Exchanges a refresh token for a new access token and a new refresh token.
+// Presenting a refresh token that was already rotated means it was stolen or replayed,
+// so the whole token family is revoked and the user has to log in again.
+func Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Expiry is checked by the database clock, the column has no time zone
+	var tokenID, userID int
+	var familyID string
+	var expired bool
+	var revokedAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, user_id, family_id, expires_at <= NOW(), revoked_at FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE",
+		utils.HashToken(req.RefreshToken),
+	).Scan(&tokenID, &userID, &familyID, &expired, &revokedAt)
+
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Reuse detection, the token was already rotated or revoked
+	if revokedAt.Valid {
+		if err = revokeTokenFamily(tx, familyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+		return
+	}
+
+	if expired {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired, please log in again"})
+		return
+	}
+
+	var user models.User
+	err = tx.QueryRow(
+		"SELECT id, name, email, COALESCE(handle, ''), is_verified, status, created_at FROM users WHERE id = $1",
+		userID,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Handle, &user.IsVerified, &user.Status, &user.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Disabling revokes every session, this catches a refresh racing with it
+	if user.Status == userDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+
+	// Rotate: the presented token is revoked and replaced by a new one in the same family
+	response, newTokenID, err := issueSession(tx, user, familyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	_, err = tx.Exec(
+		"UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $1 WHERE id = $2",
+		newTokenID, tokenID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Ends the session of the given refresh token on the server.
+// The access token stays valid until it expires, which is why access tokens are short lived.
+func Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var familyID string
+	err := config.DB.QueryRow(
+		"SELECT family_id FROM refresh_tokens WHERE token_hash = $1",
+		utils.HashToken(req.RefreshToken),
+	).Scan(&familyID)
+
+	if err == sql.ErrNoRows {
+		// Unknown tokens are already logged out
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err = revokeTokenFamily(config.DB, familyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/utils"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loginChallengeTTL      = 5 * time.Minute
+	maxLoginChallengeTries = 5
+	recoveryCodeCount      = 10
+)
+
+// Starts enrollment by generating a new secret. Two-factor authentication stays off until EnableTwoFactor confirms a code.
+func SetupTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var email string
+	var enabled bool
+	err := config.DB.QueryRow("SELECT email, totp_enabled FROM users WHERE id = $1", userID).Scan(&email, &enabled)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	_, err = config.DB.Exec("UPDATE users SET totp_secret = $1, totp_last_step = 0 WHERE id = $2", secret, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(secret, email),
+	})
+}
+
+// Turns two-factor authentication on once the user proves their authenticator app produces valid codes.
+// The recovery codes are only shown in this response.
+func EnableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+	err = tx.QueryRow("SELECT totp_secret, totp_enabled FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&secret, &enabled)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !secret.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Call /2fa/setup first"})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(secret.String, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled = true, totp_last_step = $1 WHERE id = $2", step, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(tx, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe, each can be used once.",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// Turns two-factor authentication off, which needs both the password and a current code
+func DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var password string
+	var enabled bool
+	err = tx.QueryRow("SELECT password, totp_enabled FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&password, &enabled)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.Password, password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	ok, err := verifySecondFactor(tx, userID.(int), req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled = false, totp_secret = NULL, totp_last_step = 0 WHERE id = $1", userID)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Second step of a two-factor login: exchanges the challenge token from /login and a TOTP or recovery code for tokens
+func LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Codes are throttled like passwords, otherwise they could be guessed with a fresh challenge from every login
+	retryAfter, err := ipRetryAfter(c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if retryAfter > 0 {
+		respondLoginThrottled(c, http.StatusTooManyRequests, "Too many failed login attempts, please try again later", retryAfter)
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Expiry is checked by the database clock, the column has no time zone
+	var challengeID, userID, attempts int
+	var expired bool
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		"SELECT id, user_id, expires_at <= NOW(), attempts, used_at FROM login_challenges WHERE token_hash = $1 FOR UPDATE",
+		utils.HashToken(req.ChallengeToken),
+	).Scan(&challengeID, &userID, &expired, &attempts, &usedAt)
+
+	if err == sql.ErrNoRows || (err == nil && (usedAt.Valid || attempts >= maxLoginChallengeTries || expired)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or expired, please log in again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var user models.User
+	var failedAttempts int
+	var secondsSinceFailure, lockedSeconds float64
+	err = tx.QueryRow(
+		`SELECT id, name, email, COALESCE(handle, ''), is_verified, status, totp_enabled, created_at, failed_login_attempts,
+			COALESCE(EXTRACT(EPOCH FROM NOW() - last_failed_login_at), 0),
+			COALESCE(EXTRACT(EPOCH FROM locked_until - NOW()), 0)
+		 FROM users WHERE id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Handle, &user.IsVerified, &user.Status, &user.TwoFactorEnabled, &user.CreatedAt,
+		&failedAttempts, &secondsSinceFailure, &lockedSeconds)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or expired, please log in again"})
+		return
+	}
+
+	// The user may have been disabled between the password and the code step
+	if user.Status == userDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": accountDisabledMessage})
+		return
+	}
+
+	// The lockout applies to the code step as well. The user row is released before the attempt is recorded,
+	// login_attempts references it.
+	if lockedSeconds > 0 {
+		tx.Rollback()
+		recordLoginAttempt(c, user.Email, user.ID, loginFailureLocked)
+		respondLoginThrottled(c, http.StatusLocked, "Account is temporarily locked after too many failed login attempts. Check your email for an unlock link or try again later.", secondsToDuration(lockedSeconds))
+		return
+	}
+	if wait := utils.LoginDelay(failedAttempts) - secondsToDuration(secondsSinceFailure); failedAttempts > 0 && wait > 0 {
+		tx.Rollback()
+		recordLoginAttempt(c, user.Email, user.ID, loginFailureThrottled)
+		respondLoginThrottled(c, http.StatusTooManyRequests, "Too many failed login attempts, please wait before trying again", wait)
+		return
+	}
+
+	ok, err := verifySecondFactor(tx, userID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Count failures on the challenge so codes cannot be guessed, the challenge dies after a few wrong codes.
+	// A wrong code is also a failed login of the account and of the address.
+	if !ok {
+		_, err = tx.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1", challengeID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		recordLoginAttempt(c, user.Email, user.ID, loginFailureWrongCode)
+		locked, err := registerFailedLogin(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if locked {
+			respondLoginThrottled(c, http.StatusLocked, "Too many failed login attempts, the account is temporarily locked. We emailed you a link to unlock it.", utils.LoginLockoutDuration())
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE login_challenges SET used_at = NOW() WHERE id = $1", challengeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err = clearFailedLogins(tx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	response, _, err := issueSession(tx, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	recordLoginAttempt(c, user.Email, user.ID, "")
+	c.JSON(http.StatusOK, response)
+}
+
+// createLoginChallenge stores a short lived challenge for the code step of a two-factor login.
+// The challenge is an opaque token rather than a JWT so it can never be mistaken for an access token.
+func createLoginChallenge(userID int) (models.TwoFactorChallengeResponse, error) {
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return models.TwoFactorChallengeResponse{}, err
+	}
+
+	_, err = config.DB.Exec(
+		"INSERT INTO login_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, NOW() + make_interval(secs => $3))",
+		userID, utils.HashToken(token), int(loginChallengeTTL.Seconds()),
+	)
+	if err != nil {
+		return models.TwoFactorChallengeResponse{}, err
+	}
+
+	return models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(loginChallengeTTL.Seconds()),
+	}, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// The user row must already be locked by the caller's transaction.
+func verifySecondFactor(tx *sql.Tx, userID int, code string) (bool, error) {
+	var secret sql.NullString
+	var lastStep int64
+	err := tx.QueryRow("SELECT totp_secret, totp_last_step FROM users WHERE id = $1", userID).Scan(&secret, &lastStep)
+	if err != nil {
+		return false, err
+	}
+
+	if secret.Valid {
+		// A code for a step that was already used is a replay
+		if step, ok := utils.ValidateTOTP(secret.String, code, time.Now()); ok && step > lastStep {
+			_, err = tx.Exec("UPDATE users SET totp_last_step = $1 WHERE id = $2", step, userID)
+			return err == nil, err
+		}
+	}
+
+	result, err := tx.Exec(
+		"UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, utils.HashToken(utils.NormalizeRecoveryCode(code)),
+	)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// replaceRecoveryCodes discards the user's old recovery codes and stores the hashes of a new set
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		_, err = tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
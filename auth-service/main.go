@@ -4,12 +4,17 @@ import (
 	"auth-service/config"
 	"auth-service/handlers"
 	"auth-service/middleware"
+	"auth-service/utils"
+	"flag"
 	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	bootstrapAdmin := flag.String("bootstrap-admin", "", "grant the admin role to the user with this email and exit")
+	flag.Parse()
+
 	// Initialize database
 	config.InitDB()
 	defer config.CloseDB()
@@ -17,6 +22,19 @@ func main() {
 	// Run migrations
 	config.RunMigrations()
 
+	// go run . -bootstrap-admin=you@example.com creates the first admin
+	if *bootstrapAdmin != "" {
+		if err := handlers.BootstrapAdmin(*bootstrapAdmin); err != nil {
+			log.Fatal("Failed to bootstrap admin:", err)
+		}
+		return
+	}
+
+	// Load the keys that sign access tokens
+	if err := utils.InitSigningKeys(); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
 	router := gin.Default()
 
 	// Health check
@@ -24,9 +42,18 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok", "service": "auth-service"})
 	})
 
+	// Public keys other services use to verify access tokens
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
 	// Public routes
 	router.POST("/register", handlers.Register)
 	router.POST("/login", handlers.Login)
+	router.POST("/login/2fa", handlers.LoginTwoFactor)
+	router.POST("/refresh", handlers.Refresh)
+	router.POST("/logout", handlers.Logout)
+	router.POST("/forgot-password", handlers.ForgotPassword)
+	router.POST("/reset-password", handlers.ResetPassword)
+	router.POST("/unlock-account", handlers.UnlockAccount)
 	router.GET("/verify-email", handlers.VerifyEmail)
 	router.POST("/send-verification", handlers.SendVerificationEmail)
 
@@ -35,7 +62,34 @@ func main() {
 	protected.Use(middleware.AuthMiddleware())
 	{
 		protected.GET("/me", handlers.GetCurrentUser)
-		protected.GET("/users", handlers.GetAllUsers)
+		protected.PUT("/me/handle", handlers.UpdateHandle)
+		protected.POST("/2fa/setup", handlers.SetupTwoFactor)
+		protected.POST("/2fa/enable", handlers.EnableTwoFactor)
+		protected.POST("/2fa/disable", handlers.DisableTwoFactor)
+	}
+
+	// Admin routes, each guarded by a permission from the caller's roles
+	admin := router.Group("/admin")
+	admin.Use(middleware.AuthMiddleware())
+	{
+		admin.GET("/roles", middleware.RequirePermission("role:assign"), handlers.ListRoles)
+		admin.POST("/users/:id/roles", middleware.RequirePermission("role:assign"), handlers.AssignRole)
+		admin.DELETE("/users/:id/roles/:role", middleware.RequirePermission("role:assign"), handlers.RemoveRole)
+
+		admin.GET("/users", middleware.RequirePermission("user:read"), handlers.SearchUsers)
+		admin.GET("/users/:id", middleware.RequirePermission("user:read"), handlers.AdminGetUser)
+		admin.POST("/users/:id/verify", middleware.RequirePermission("user:verify"), handlers.AdminVerifyUser)
+		admin.POST("/users/:id/disable", middleware.RequirePermission("user:disable"), handlers.DisableUser)
+		admin.POST("/users/:id/enable", middleware.RequirePermission("user:disable"), handlers.EnableUser)
+		admin.GET("/audit-log", middleware.RequirePermission("audit:read"), handlers.GetAuditLog)
+	}
+
+	// Internal routes for other services (require INTERNAL_API_KEY)
+	internal := router.Group("/internal")
+	internal.Use(middleware.InternalAuth())
+	{
+		internal.GET("/users/lookup", handlers.LookupUser)
+		internal.GET("/users/:id", handlers.GetUserByID)
 	}
 
 	port := config.GetEnv("PORT", "8081")
@@ -37,6 +37,8 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("roles", claims.Roles)
+		c.Set("permissions", claims.Permissions)
 		c.Next() //continue chain
 	}
 }
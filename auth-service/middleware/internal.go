@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"auth-service/config"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalAuth guards service-to-service routes with the shared INTERNAL_API_KEY.
+// Requests are rejected when the key is not configured so the routes are never left open.
+func InternalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := config.GetEnv("INTERNAL_API_KEY", "")
+		provided := c.GetHeader("X-Internal-Api-Key")
+
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid internal API key"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AdminActionRequest carries the reason every admin action has to give for the audit log
+type AdminActionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// AdminUserDetail is a user as support staff see it, including the login lockout state
+type AdminUserDetail struct {
+	User
+	FailedLoginAttempts int        `json:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+}
+
+// AuditLogEntry struct for database table auth_audit_log, one admin action on a user
+type AuditLogEntry struct {
+	ID          int             `json:"id"`
+	AdminUserID int             `json:"admin_user_id"`
+	Action      string          `json:"action"`
+	UserID      int             `json:"user_id"`
+	Reason      string          `json:"reason"`
+	Details     json.RawMessage `json:"details"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
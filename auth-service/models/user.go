@@ -7,8 +7,12 @@ type User struct {
 	ID                int       `json:"id"`
 	Name              string    `json:"name"`
 	Email             string    `json:"email"`
+	Handle            string    `json:"handle,omitempty"`
 	Password          string    `json:"-"`
 	IsVerified        bool      `json:"is_verified"`
+	Status            string    `json:"status,omitempty"`
+	TwoFactorEnabled  bool      `json:"two_factor_enabled"`
+	Roles             []string  `json:"roles,omitempty"`
 	VerificationToken string    `json:"-"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
@@ -19,6 +23,7 @@ type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	Handle   string `json:"handle"`
 }
 
 // Login takes Email and Password
@@ -27,12 +32,67 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse returns Token and User details
+// LoginResponse returns the access Token, the RefreshToken used to renew it and User details
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+}
+
+// RefreshRequest takes the refresh token for /refresh and /logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type SendVerificationRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest sets a new password with the token from the reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// UnlockAccountRequest takes the token from the account locked email
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UpdateHandleRequest sets the public handle other users can send money to
+type UpdateHandleRequest struct {
+	Handle string `json:"handle" binding:"required"`
+}
+
+// TwoFactorChallengeResponse is returned by /login instead of tokens when the user has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest exchanges the challenge token and a TOTP or recovery code for tokens
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorSetupResponse carries the secret to enter in an authenticator app, or the URI to show as a QR code
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
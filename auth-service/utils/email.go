@@ -5,28 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net/smtp" //SMTP server package for Go
+	"time"
 )
 
-func SendVerificationEmail(toEmail, token string) {
-	//using .env configurations for SMTP server
-	smtpHost := config.GetEnv("SMTP_HOST", "smtp.gmail.com")
-	smtpPort := config.GetEnv("SMTP_PORT", "587")
-	smtpUsername := config.GetEnv("SMTP_USERNAME", "")
-	smtpPassword := config.GetEnv("SMTP_PASSWORD", "")
-	fromEmail := config.GetEnv("FROM_EMAIL", "")
-	frontendURL := config.GetEnv("FRONTEND_URL", "http://localhost:5173")
-
-	if smtpUsername == "" || smtpPassword == "" {
-		log.Println("SMTP credentials not configured. Verification email not sent.")
-		log.Printf("Verification link: %s/verify-email?token=%s", frontendURL, token)
-		return
-	}
-
-	verificationURL := fmt.Sprintf("%s/verify-email?token=%s", frontendURL, token)
-
-	//HTML body showing in the email content
-	subject := "Email Verification - Money Transfer App"
-	body := fmt.Sprintf(`
+// emailLayout is the HTML shared by all emails: heading, intro paragraphs, button link, button label, plain link, closing paragraphs
+const emailLayout = `
 <!DOCTYPE html>
 <html>
 <head>
@@ -52,16 +35,14 @@ func SendVerificationEmail(toEmail, token string) {
 <body>
     <div class="container">
         <div class="header">
-            <h1>Verify Your Email</h1>
+            <h1>%s</h1>
         </div>
         <div class="content">
-            <p>Thank you for registering with our Money Transfer App!</p>
-            <p>Please click the button below to verify your email address:</p>
-            <a href="%s" class="button" style="background-color: #4F46E5; color: #ffffff; padding: 15px 40px; text-decoration: none; border-radius: 5px; display: inline-block; font-weight: bold; font-size: 16px;">Verify Email</a>
+            %s
+            <a href="%s" class="button" style="background-color: #4F46E5; color: #ffffff; padding: 15px 40px; text-decoration: none; border-radius: 5px; display: inline-block; font-weight: bold; font-size: 16px;">%s</a>
             <p>Or copy and paste this link into your browser:</p>
             <p style="word-break: break-all; color: #4F46E5;">%s</p>
-            <p>This link will expire in 24 hours.</p>
-            <p>If you didn't create an account, please ignore this email.</p>
+            %s
         </div>
         <div class="footer">
             <p>© 2025 Money Transfer App. All rights reserved.</p>
@@ -69,7 +50,77 @@ func SendVerificationEmail(toEmail, token string) {
     </div>
 </body>
 </html>
-	`, verificationURL, verificationURL)
+	`
+
+func SendVerificationEmail(toEmail, token string, validFor time.Duration) {
+	frontendURL := config.GetEnv("FRONTEND_URL", "http://localhost:5173")
+	verificationURL := fmt.Sprintf("%s/verify-email?token=%s", frontendURL, token)
+
+	//HTML body showing in the email content
+	body := fmt.Sprintf(emailLayout,
+		"Verify Your Email",
+		`<p>Thank you for registering with our Money Transfer App!</p>
+            <p>Please click the button below to verify your email address:</p>`,
+		verificationURL, "Verify Email", verificationURL,
+		fmt.Sprintf(`<p>This link will expire in %d hours.</p>
+            <p>If you didn't create an account, please ignore this email.</p>`, int(validFor.Hours())),
+	)
+
+	sendEmail(toEmail, "Email Verification - Money Transfer App", body, "Verification", verificationURL)
+}
+
+func SendPasswordResetEmail(toEmail, token string, validFor time.Duration) {
+	frontendURL := config.GetEnv("FRONTEND_URL", "http://localhost:5173")
+	resetURL := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, token)
+
+	body := fmt.Sprintf(emailLayout,
+		"Reset Your Password",
+		`<p>We received a request to reset the password of your Money Transfer App account.</p>
+            <p>Please click the button below to choose a new password:</p>`,
+		resetURL, "Reset Password", resetURL,
+		fmt.Sprintf(`<p>This link will expire in %d minutes and can only be used once.</p>
+            <p>If you didn't ask to reset your password, please ignore this email. Your password will not change.</p>`, int(validFor.Minutes())),
+	)
+
+	sendEmail(toEmail, "Password Reset - Money Transfer App", body, "Password reset", resetURL)
+}
+
+func SendAccountUnlockEmail(toEmail, token string, lockedFor time.Duration) {
+	frontendURL := config.GetEnv("FRONTEND_URL", "http://localhost:5173")
+	unlockURL := fmt.Sprintf("%s/unlock-account?token=%s", frontendURL, token)
+
+	body := fmt.Sprintf(emailLayout,
+		"Your Account Was Locked",
+		fmt.Sprintf(`<p>There were too many failed login attempts on your Money Transfer App account, so it has been locked for %d minutes.</p>
+            <p>If this was you, click the button below to unlock it now:</p>`, int(lockedFor.Minutes())),
+		unlockURL, "Unlock Account", unlockURL,
+		`<p>If this wasn't you, someone may be trying to guess your password. Leave the account locked and consider resetting your password.</p>`,
+	)
+
+	sendEmail(toEmail, "Account Locked - Money Transfer App", body, "Account unlock", unlockURL)
+}
+
+// sendEmail delivers an HTML email over SMTP. The link in it is a credential, so it is only logged
+// when LOG_EMAIL_LINKS=true, which lets local setups without SMTP still work.
+func sendEmail(toEmail, subject, body, kind, link string) {
+	logLink := func() {
+		if config.GetEnv("LOG_EMAIL_LINKS", "") == "true" {
+			log.Printf("%s link for %s: %s", kind, toEmail, link)
+		}
+	}
+
+	//using .env configurations for SMTP server
+	smtpHost := config.GetEnv("SMTP_HOST", "smtp.gmail.com")
+	smtpPort := config.GetEnv("SMTP_PORT", "587")
+	smtpUsername := config.GetEnv("SMTP_USERNAME", "")
+	smtpPassword := config.GetEnv("SMTP_PASSWORD", "")
+	fromEmail := config.GetEnv("FROM_EMAIL", "")
+
+	if smtpUsername == "" || smtpPassword == "" {
+		log.Printf("SMTP credentials not configured. %s email to %s not sent.", kind, toEmail)
+		logLink()
+		return
+	}
 
 	//message for email content
 	message := []byte(fmt.Sprintf("To: %s\r\n"+
@@ -83,9 +134,9 @@ func SendVerificationEmail(toEmail, token string) {
 	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, fromEmail, []string{toEmail}, message)
 
 	if err != nil {
-		log.Printf("Failed to send email to %s: %v", toEmail, err)
-		log.Printf("Verification link: %s", verificationURL)
+		log.Printf("Failed to send %s email to %s: %v", kind, toEmail, err)
+		logLink()
 	} else {
-		log.Printf("Verification email sent to %s", toEmail)
+		log.Printf("%s email sent to %s", kind, toEmail)
 	}
 }
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+var ErrInvalidHandle = errors.New("handle must be 3-30 characters of letters, numbers or underscores")
+
+// Normalizes a user handle to lower case without a leading @ and checks the allowed characters
+func NormalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !handlePattern.MatchString(handle) {
+		return "", ErrInvalidHandle
+	}
+	return handle, nil
+}
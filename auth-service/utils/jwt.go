@@ -3,42 +3,77 @@ package utils
 import (
 	"auth-service/config"
 	"errors"
+	"fmt"
+	"strconv"
 	"time" //time library for Go using for handle token expirations
 
 	"github.com/golang-jwt/jwt/v5" //JWT library for Go
 )
 
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID      int      `json:"user_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// Create JWT token for given user ID and email
-func GenerateJWT(userID int, email string) (string, error) {
-	jwtSecret := config.GetEnv("JWT_SECRET", "your-secret-key")
+// Lifetime of access tokens, kept short because they cannot be revoked before they expire
+func AccessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(config.GetEnv("ACCESS_TOKEN_TTL_MINUTES", "15"))
+	if err != nil || minutes < 1 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Lifetime of refresh tokens, each refresh rotates the token and starts a new lifetime
+func RefreshTokenTTL() time.Duration {
+	hours, err := strconv.Atoi(config.GetEnv("REFRESH_TOKEN_TTL_HOURS", "720"))
+	if err != nil || hours < 1 {
+		hours = 720
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Create JWT token for given user ID and email with the user's roles and permissions,
+// signed with the active key and marked with its kid
+func GenerateJWT(userID int, email string, roles, permissions []string) (string, error) {
+	key, ok := signingKeys[activeKeyKID]
+	if !ok {
+		return "", errors.New("signing keys are not initialized")
+	}
 
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:      userID,
+		Email:       email,
+		Roles:       roles,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
-			//jwt expires within 24 hours
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			//short lived access token, clients renew it with their refresh token
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.key)
 }
 
 func ValidateJWT(tokenString string) (*Claims, error) {
-	jwtSecret := config.GetEnv("JWT_SECRET", "your-secret-key")
-
-	//Parse secret key for sign the token
+	//Pick the verification key by the kid header, the algorithm must match the key type
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.key.Public(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"auth-service/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is one private key of the key set, identified by the kid header of the tokens it signs
+type signingKey struct {
+	kid    string
+	key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// JWK is the public half of a signing key as published in the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// The key set loaded by InitSigningKeys. Every key verifies tokens and is published,
+// only the active key signs new tokens.
+var (
+	signingKeys  map[string]signingKey
+	activeKeyKID string
+)
+
+// InitSigningKeys loads every PKCS #8 private key (Ed25519 or RSA) from JWT_KEYS_DIR, using the file name without .pem as kid.
+// JWT_ACTIVE_KID picks the key that signs, it may be left out when the directory holds a single key.
+// Without JWT_KEYS_DIR the service refuses to start, unless JWT_ALLOW_EPHEMERAL_KEY=true allows a temporary key for development.
+//
+// To rotate, add the new key file and restart so it is published, switch JWT_ACTIVE_KID once verifiers
+// have refreshed their JWKS cache, and delete the old file after the access token lifetime has passed.
+func InitSigningKeys() error {
+	keysDir := config.GetEnv("JWT_KEYS_DIR", "")
+	if keysDir == "" {
+		// Tokens signed with a throwaway key stop verifying when the service restarts, so it has to be asked for
+		if config.GetEnv("JWT_ALLOW_EPHEMERAL_KEY", "") != "true" {
+			return errors.New("JWT_KEYS_DIR must be set, or JWT_ALLOW_EPHEMERAL_KEY=true for a temporary development key")
+		}
+		log.Println("JWT_KEYS_DIR not set, signing tokens with a temporary Ed25519 key")
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		signingKeys = map[string]signingKey{"ephemeral": {kid: "ephemeral", key: privateKey, method: jwt.SigningMethodEdDSA}}
+		activeKeyKID = "ephemeral"
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(keysDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no .pem keys found in %s", keysDir)
+	}
+
+	keys := make(map[string]signingKey, len(paths))
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := loadSigningKey(path, kid)
+		if err != nil {
+			return err
+		}
+		keys[kid] = key
+	}
+
+	activeKID := config.GetEnv("JWT_ACTIVE_KID", "")
+	if activeKID == "" {
+		if len(keys) > 1 {
+			return errors.New("JWT_ACTIVE_KID must be set when JWT_KEYS_DIR holds more than one key")
+		}
+		for kid := range keys {
+			activeKID = kid
+		}
+	}
+	if _, ok := keys[activeKID]; !ok {
+		return fmt.Errorf("JWT_ACTIVE_KID %q has no key in %s", activeKID, keysDir)
+	}
+
+	signingKeys = keys
+	activeKeyKID = activeKID
+	log.Printf("Loaded %d JWT signing keys, signing with %s", len(keys), activeKID)
+	return nil
+}
+
+func loadSigningKey(path, kid string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, fmt.Errorf("%s is not a PEM file", path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		return signingKey{kid: kid, key: key, method: jwt.SigningMethodEdDSA}, nil
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return signingKey{}, fmt.Errorf("%s: RSA keys must be at least 2048 bits", path)
+		}
+		return signingKey{kid: kid, key: key, method: jwt.SigningMethodRS256}, nil
+	default:
+		return signingKey{}, fmt.Errorf("%s: unsupported key type %T, use Ed25519 or RSA", path, parsed)
+	}
+}
+
+// PublicJWKS returns the public keys of every loaded key for the /.well-known/jwks.json endpoint
+func PublicJWKS() JWKS {
+	kids := make([]string, 0, len(signingKeys))
+	for kid := range signingKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, kid := range kids {
+		key := signingKeys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+
+		switch public := key.key.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"auth-service/config"
+	"strconv"
+	"time"
+)
+
+// Consecutive failed logins after which an account is locked
+func LoginMaxFailedAttempts() int {
+	return envInt("LOGIN_MAX_FAILED_ATTEMPTS", 5)
+}
+
+// How long a locked account stays locked unless it is unlocked by email
+func LoginLockoutDuration() time.Duration {
+	return time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute
+}
+
+// Failed logins from one IP address within LoginIPWindow before the address is throttled, across all emails
+func LoginMaxFailedAttemptsPerIP() int {
+	return envInt("LOGIN_MAX_FAILED_ATTEMPTS_PER_IP", 20)
+}
+
+// Sliding window for LoginMaxFailedAttemptsPerIP
+func LoginIPWindow() time.Duration {
+	return 15 * time.Minute
+}
+
+// Minimum wait before the next login after the given number of consecutive failures.
+// It doubles with every failure (1s, 2s, 4s, ...) up to 30 seconds.
+func LoginDelay(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	if failures > 5 {
+		return 30 * time.Second
+	}
+	return time.Duration(1<<(failures-1)) * time.Second
+}
+
+// Password reset emails that can be requested for one email address within PasswordResetWindow
+func PasswordResetMaxPerEmail() int {
+	return envInt("PASSWORD_RESET_MAX_PER_EMAIL", 3)
+}
+
+// Password reset emails that can be requested from one IP address within PasswordResetWindow, across all emails
+func PasswordResetMaxPerIP() int {
+	return envInt("PASSWORD_RESET_MAX_PER_IP", 10)
+}
+
+// Sliding window for PasswordResetMaxPerEmail and PasswordResetMaxPerIP
+func PasswordResetWindow() time.Duration {
+	return time.Hour
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(config.GetEnv(key, strconv.Itoa(fallback)))
+	if err != nil || value < 1 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"auth-service/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Generates a random opaque token for refresh tokens and emailed links
+func GenerateSecureToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Hashes an opaque token before it is stored, so a database leak does not leak usable tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lifetime of password reset links
+func PasswordResetTTL() time.Duration {
+	minutes, err := strconv.Atoi(config.GetEnv("PASSWORD_RESET_TTL_MINUTES", "30"))
+	if err != nil || minutes < 1 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Lifetime of email verification links
+func VerificationTokenTTL() time.Duration {
+	hours, err := strconv.Atoi(config.GetEnv("VERIFICATION_TOKEN_TTL_HOURS", "24"))
+	if err != nil || hours < 1 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -4,6 +4,9 @@ import Login from './pages/Login'
 import Register from './pages/Register'
 import Dashboard from './pages/Dashboard'
 import VerifyEmail from './pages/VerifyEmail'
+import ForgotPassword from './pages/ForgotPassword'
+import ResetPassword from './pages/ResetPassword'
+import UnlockAccount from './pages/UnlockAccount'
 import './App.css'
 
 function PrivateRoute({ children }) {
@@ -39,6 +42,16 @@ function App() {
             } 
           />
           <Route path="/verify-email" element={<VerifyEmail />} />
+          <Route 
+            path="/forgot-password" 
+            element={
+              <PublicRoute>
+                <ForgotPassword />
+              </PublicRoute>
+            } 
+          />
+          <Route path="/reset-password" element={<ResetPassword />} />
+          <Route path="/unlock-account" element={<UnlockAccount />} />
           <Route 
             path="/dashboard" 
             element={
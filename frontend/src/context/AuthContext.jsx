@@ -1,4 +1,4 @@
-import { createContext, useContext, useState, useEffect } from 'react'
+import { createContext, useContext, useState, useEffect, useCallback, useRef } from 'react'
 import axios from 'axios'
 
 const AuthContext = createContext()
@@ -22,15 +22,82 @@ export function AuthProvider({ children }) {
     setLoading(false)
   }, [])
 
+  const clearSession = useCallback(() => {
+    localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
+    localStorage.removeItem('user')
+    delete axios.defaults.headers.common['Authorization']
+    setUser(null)
+  }, [])
+
+  const storeSession = (data) => {
+    localStorage.setItem('token', data.token)
+    localStorage.setItem('refresh_token', data.refresh_token)
+    localStorage.setItem('user', JSON.stringify(data.user))
+    axios.defaults.headers.common['Authorization'] = `Bearer ${data.token}`
+    setUser(data.user)
+  }
+
+  // The refresh in flight, shared by every request that fails while it runs. Each refresh token can only be
+  // used once, a second refresh with the same token would look like reuse and end the session.
+  const pendingRefresh = useRef(null)
+
+  // When the access token expires, swap the refresh token for a new pair once and retry the request
+  useEffect(() => {
+    const refreshSession = () => {
+      if (!pendingRefresh.current) {
+        const refreshToken = localStorage.getItem('refresh_token')
+        pendingRefresh.current = axios.post('/api/auth/refresh', { refresh_token: refreshToken })
+          .then((response) => {
+            storeSession(response.data)
+            return response.data.token
+          })
+          .finally(() => {
+            pendingRefresh.current = null
+          })
+      }
+      return pendingRefresh.current
+    }
+
+    const interceptor = axios.interceptors.response.use(
+      (response) => response,
+      async (error) => {
+        const original = error.config
+        const refreshToken = localStorage.getItem('refresh_token')
+        const isAuthCall = original?.url?.startsWith('/api/auth/refresh') || original?.url?.startsWith('/api/auth/login')
+
+        if (error.response?.status !== 401 || !refreshToken || original._retried || isAuthCall) {
+          return Promise.reject(error)
+        }
+
+        original._retried = true
+        try {
+          // A request sent with an access token that has been replaced since only needs to be sent again
+          const currentToken = localStorage.getItem('token')
+          const token = original.headers['Authorization'] !== `Bearer ${currentToken}` ? currentToken : await refreshSession()
+          original.headers['Authorization'] = `Bearer ${token}`
+          return axios(original)
+        } catch (refreshError) {
+          clearSession()
+          return Promise.reject(error)
+        }
+      }
+    )
+    return () => axios.interceptors.response.eject(interceptor)
+  }, [clearSession])
+
+  // Returns { two_factor_required, challenge_token } instead of logging in when the account uses two-factor authentication
   const login = async (email, password) => {
     const response = await axios.post('/api/auth/login', { email, password })
-    const { token, user: userData } = response.data
-    
-    localStorage.setItem('token', token)
-    localStorage.setItem('user', JSON.stringify(userData))
-    axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
-    setUser(userData)
-    
+    if (!response.data.two_factor_required) {
+      storeSession(response.data)
+    }
+    return response.data
+  }
+
+  const loginTwoFactor = async (challengeToken, code) => {
+    const response = await axios.post('/api/auth/login/2fa', { challenge_token: challengeToken, code })
+    storeSession(response.data)
     return response.data
   }
 
@@ -39,16 +106,19 @@ export function AuthProvider({ children }) {
     return response.data
   }
 
-  const logout = () => {
-    localStorage.removeItem('token')
-    localStorage.removeItem('user')
-    delete axios.defaults.headers.common['Authorization']
-    setUser(null)
+  const logout = async () => {
+    const refreshToken = localStorage.getItem('refresh_token')
+    if (refreshToken) {
+      // End the session on the server as well, the local session is cleared either way
+      await axios.post('/api/auth/logout', { refresh_token: refreshToken }).catch(() => {})
+    }
+    clearSession()
   }
 
   const value = {
     user,
     login,
+    loginTwoFactor,
     register,
     logout,
     loading
@@ -6,7 +6,6 @@ import './Dashboard.css'
 export default function Dashboard() {
   const { user, logout } = useAuth()
   const [wallet, setWallet] = useState(null)
-  const [users, setUsers] = useState([])
   const [transactions, setTransactions] = useState([])
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState('')
@@ -15,7 +14,7 @@ export default function Dashboard() {
   // Transfer form
   const [showTransferModal, setShowTransferModal] = useState(false) //hook for transfer modal
   const [transferData, setTransferData] = useState({ //hook for transfer data
-    receiver_id: '',
+    recipient: '',
     amount: '',
     description: ''
   })
@@ -26,7 +25,6 @@ export default function Dashboard() {
 
   useEffect(() => {
     fetchWallet() //useEffect hook for fetching wallet
-    fetchUsers() //useEffect hook for fetching users
     fetchTransactions() //useEffect hook for fetching transactions
   }, [])
 
@@ -43,21 +41,12 @@ export default function Dashboard() {
     }
   }
 
-  const fetchUsers = async () => { //async function used to fetch users
-    try {
-      const response = await axios.get('/api/auth/users')
-      setUsers(response.data.filter(u => u.id !== user.id))
-    } catch (err) {
-      console.error('Failed to fetch users:', err)
-    }
-  }
-
   const fetchTransactions = async () => { //async function used to fetch transactions
     try {
       console.log('Fetching transactions...')
       const response = await axios.get('/api/transactions')
       console.log('Transactions response:', response.data)
-      setTransactions(response.data)
+      setTransactions(response.data.transactions)
     } catch (err) {
       console.error('Failed to fetch transactions:', err.response?.data || err.message)
     }
@@ -81,22 +70,28 @@ export default function Dashboard() {
       return
     }
 
-    // Check if recipient is selected
-    if (!transferData.receiver_id) {
-      setError('Please select a recipient')
+    // Check if recipient is entered
+    const recipient = transferData.recipient.trim()
+    if (!recipient) {
+      setError('Please enter the recipient email or @handle')
       return
     }
 
+    // The server resolves the recipient, "@name" is a handle and anything else with an @ is an email
+    const receiver = recipient.startsWith('@') || !recipient.includes('@')
+      ? { receiver_handle: recipient.replace(/^@/, '') }
+      : { receiver_email: recipient }
+
     try {
       await axios.post('/api/transactions/transfer', {
-        receiver_id: parseInt(transferData.receiver_id),
+        ...receiver,
         amount: amount,
         description: transferData.description
       })
       
       setSuccess('Transfer successful!')
       setShowTransferModal(false)
-      setTransferData({ receiver_id: '', amount: '', description: '' })
+      setTransferData({ recipient: '', amount: '', description: '' })
       
       fetchWallet()
       fetchTransactions()
@@ -147,15 +142,9 @@ export default function Dashboard() {
     }
   }
 
-  const getReceiverName = (receiverId) => {
-    const receiver = users.find(u => u.id === receiverId)
-    return receiver ? receiver.name : `User #${receiverId}`
-  }
-
-  //function to get sender name to add in description if no description is provided
-  const getSenderName = (senderId) => {
-    const sender = users.find(u => u.id === senderId)
-    return sender ? sender.name : `User #${senderId}`
+  //the transaction history names the other user of a transfer, used when no description is provided
+  const getCounterpartyName = (tx, otherId) => {
+    return tx.counterparty ? tx.counterparty.name : `User #${otherId}`
   }
 
   if (loading) {
@@ -226,11 +215,11 @@ export default function Dashboard() {
                     </td>
                     <td>
                       {tx.transaction_type === 'transfer' && tx.sender_id === user.id
-                        ? `To ${getReceiverName(tx.receiver_id)}`
+                        ? `To ${getCounterpartyName(tx, tx.receiver_id)}`
                         : tx.transaction_type === 'transfer' && tx.receiver_id === user.id
                         ? (tx.description && tx.description.trim() !== '' 
                            ? tx.description 
-                           : `From ${getSenderName(tx.sender_id)}`)
+                           : `From ${getCounterpartyName(tx, tx.sender_id)}`)
                         : tx.description}
                     </td>
                     <td className={tx.sender_id === user.id && tx.transaction_type === 'transfer' ? 'amount-debit' : 'amount-credit'}>
@@ -255,17 +244,14 @@ export default function Dashboard() {
             <h3>Transfer Money</h3>
             <form onSubmit={handleTransfer}>
               <div className="form-group">
-                <label>Select Recipient</label>
-                <select
-                  value={transferData.receiver_id}
-                  onChange={(e) => setTransferData({...transferData, receiver_id: e.target.value})}
+                <label>Recipient</label>
+                <input
+                  type="text"
+                  value={transferData.recipient}
+                  onChange={(e) => setTransferData({...transferData, recipient: e.target.value})}
                   required
-                >
-                  <option value="">Choose recipient...</option>
-                  {users.map(u => (
-                    <option key={u.id} value={u.id}>{u.name} ({u.email})</option>
-                  ))}
-                </select>
+                  placeholder="Email or @handle"
+                />
               </div>
 
               <div className="form-group">
@@ -1,5 +1,5 @@
 import { useState } from 'react'
-import { useNavigate } from 'react-router-dom'
+import { Link, useNavigate } from 'react-router-dom'
 import { useAuth } from '../context/AuthContext' //hook for authentication
 import './Auth.css'
 
@@ -17,8 +17,10 @@ export default function Login() {
   const [error, setError] = useState('')
   const [success, setSuccess] = useState('')
   const [loading, setLoading] = useState(false)
+  const [challengeToken, setChallengeToken] = useState('')
+  const [twoFactorCode, setTwoFactorCode] = useState('')
   
-  const { login, register } = useAuth() //login and register functions from AuthContext
+  const { login, loginTwoFactor, register } = useAuth() //login and register functions from AuthContext
   const navigate = useNavigate()
 
   const handleChange = (e) => {
@@ -35,7 +37,12 @@ export default function Login() {
     setLoading(true)
 
     try {
-      await login(formData.email, formData.password)
+      const data = await login(formData.email, formData.password)
+      if (data.two_factor_required) {
+        // Password was correct, ask for the authenticator code next
+        setChallengeToken(data.challenge_token)
+        return
+      }
       navigate('/dashboard')
     } catch (err) {
       setError(err.response?.data?.error || 'Login failed. Please check your credentials.')
@@ -44,6 +51,26 @@ export default function Login() {
     }
   }
 
+  const handleTwoFactorSubmit = async (e) => {
+    e.preventDefault()
+    setError('')
+    setLoading(true)
+
+    try {
+      await loginTwoFactor(challengeToken, twoFactorCode)
+      navigate('/dashboard')
+    } catch (err) {
+      setError(err.response?.data?.error || 'Invalid code. Please try again.')
+      // An expired or exhausted challenge needs the password again
+      if (err.response?.data?.error?.includes('log in again')) {
+        setChallengeToken('')
+        setTwoFactorCode('')
+      }
+    } finally {
+      setLoading(false)
+    }
+  }
+
   const handleRegisterSubmit = async (e) => {
     e.preventDefault()
     setError('')
@@ -88,7 +115,28 @@ export default function Login() {
         {error && <div className="error-message">{error}</div>}
         {success && <div className="success-message">{success}</div>}
 
-        {!isRegisterMode ? (
+        {challengeToken ? (
+          // Two-factor code form
+          <form onSubmit={handleTwoFactorSubmit}>
+            <div className="form-group">
+              <label>Authentication code</label>
+              <input
+                type="text"
+                value={twoFactorCode}
+                onChange={(e) => setTwoFactorCode(e.target.value)}
+                required
+                autoFocus
+                placeholder="6-digit code or recovery code"
+              />
+            </div>
+
+            <div className="form-actions">
+              <button type="submit" className="login-btn" disabled={loading}>
+                {loading ? 'Verifying...' : 'Verify'}
+              </button>
+            </div>
+          </form>
+        ) : !isRegisterMode ? (
           // Login Form
           <div className="auth-layout">
             <div className="left-section">
@@ -144,6 +192,7 @@ export default function Login() {
                     remember me
                   </label>
                 </div>
+                <Link to="/forgot-password" className="forgot-password-link">Forgot password?</Link>
               </form>
             </div>
           </div>
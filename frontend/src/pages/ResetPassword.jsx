@@ -0,0 +1,99 @@
+import { useState } from 'react'
+import { Link, useNavigate, useSearchParams } from 'react-router-dom'
+import axios from 'axios'
+import './Auth.css'
+
+export default function ResetPassword() {
+  const [searchParams] = useSearchParams()
+  const [password, setPassword] = useState('')
+  const [retypePassword, setRetypePassword] = useState('')
+  const [error, setError] = useState('')
+  const [success, setSuccess] = useState('')
+  const [loading, setLoading] = useState(false)
+  const navigate = useNavigate()
+
+  const token = searchParams.get('token')
+
+  const handleSubmit = async (e) => {
+    e.preventDefault()
+    setError('')
+
+    if (password !== retypePassword) {
+      setError('Passwords do not match')
+      return
+    }
+
+    if (password.length < 6) {
+      setError('Password must be at least 6 characters')
+      return
+    }
+
+    setLoading(true)
+    try {
+      const response = await axios.post('/api/auth/reset-password', { token, password })
+      setSuccess(response.data.message)
+
+      // Redirect to login after 3 seconds
+      setTimeout(() => {
+        navigate('/login')
+      }, 3000)
+    } catch (err) {
+      setError(err.response?.data?.error || 'Password reset failed. The link may have expired.')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  if (!token) {
+    return (
+      <div className="app-container">
+        <div className="auth-card">
+          <h2>Reset Password</h2>
+          <div className="error-message">Invalid password reset link</div>
+          <Link to="/forgot-password">Request a new link</Link>
+        </div>
+      </div>
+    )
+  }
+
+  return (
+    <div className="app-container">
+      <div className="auth-card">
+        <h2>Reset Password</h2>
+
+        {error && <div className="error-message">{error}</div>}
+        {success && <div className="success-message">{success}</div>}
+
+        <form onSubmit={handleSubmit}>
+          <div className="form-group">
+            <label>New Password</label>
+            <input
+              type="password"
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+              required
+              placeholder="Enter new password"
+            />
+          </div>
+
+          <div className="form-group">
+            <label>Retype Password</label>
+            <input
+              type="password"
+              value={retypePassword}
+              onChange={(e) => setRetypePassword(e.target.value)}
+              required
+              placeholder="Retype new password"
+            />
+          </div>
+
+          <div className="form-actions">
+            <button type="submit" className="login-btn" disabled={loading || !!success}>
+              {loading ? 'Saving...' : 'Reset Password'}
+            </button>
+          </div>
+        </form>
+      </div>
+    </div>
+  )
+}
@@ -0,0 +1,74 @@
+import { useEffect, useState } from 'react'
+import { useNavigate, useSearchParams } from 'react-router-dom'
+import axios from 'axios'
+import './Auth.css'
+
+export default function UnlockAccount() {
+  const [searchParams] = useSearchParams()
+  const [status, setStatus] = useState('unlocking') // unlocking, success, error
+  const [message, setMessage] = useState('Unlocking your account...')
+  const navigate = useNavigate()
+
+  useEffect(() => {
+    const token = searchParams.get('token')
+
+    if (!token) {
+      setStatus('error')
+      setMessage('Invalid unlock link')
+      return
+    }
+
+    unlockAccount(token)
+  }, [searchParams])
+
+  const unlockAccount = async (token) => {
+    try {
+      const response = await axios.post('/api/auth/unlock-account', { token })
+      setStatus('success')
+      setMessage(response.data.message || 'Account unlocked')
+
+      // Redirect to login after 3 seconds
+      setTimeout(() => {
+        navigate('/login')
+      }, 3000)
+    } catch (err) {
+      setStatus('error')
+      setMessage(err.response?.data?.error || 'Failed to unlock account')
+    }
+  }
+
+  return (
+    <div className="app-container">
+      <div className="auth-card">
+        <h2>Unlock Account</h2>
+
+        {status === 'unlocking' && (
+          <div className="verification-status">
+            <div className="spinner"></div>
+            <p>{message}</p>
+          </div>
+        )}
+
+        {status === 'success' && (
+          <div className="success-message">
+            <p>{message}</p>
+            <p style={{fontSize: '14px', color: '#666'}}>Redirecting to login...</p>
+          </div>
+        )}
+
+        {status === 'error' && (
+          <div className="error-message">
+            <p>{message}</p>
+            <button 
+              onClick={() => navigate('/login')}
+              className="btn-primary"
+              style={{marginTop: '20px', padding: '12px 30px', cursor: 'pointer'}}
+            >
+              Go to Login
+            </button>
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}
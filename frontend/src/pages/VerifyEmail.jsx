@@ -5,8 +5,9 @@ import './Auth.css'
 
 export default function VerifyEmail() {
   const [searchParams] = useSearchParams()
-  const [status, setStatus] = useState('verifying') // verifying, success, error
+  const [status, setStatus] = useState('verifying') // verifying, success, expired, error
   const [message, setMessage] = useState('Verifying your email...')
+  const [email, setEmail] = useState('')
   const navigate = useNavigate()
 
   useEffect(() => {
@@ -32,11 +33,21 @@ export default function VerifyEmail() {
         navigate('/login')
       }, 3000)
     } catch (err) {
-      setStatus('error')
+      setStatus(err.response?.status === 410 ? 'expired' : 'error')
       setMessage(err.response?.data?.error || 'Verification failed. Link may be expired.')
     }
   }
 
+  const resendVerification = async (e) => {
+    e.preventDefault()
+    try {
+      const response = await axios.post('/api/auth/send-verification', { email })
+      setMessage(response.data.message || 'A new verification email has been sent')
+    } catch (err) {
+      setMessage(err.response?.data?.error || 'Failed to send a new verification email')
+    }
+  }
+
   return (
     <div className="app-container">
       <div className="auth-card">
@@ -60,6 +71,26 @@ export default function VerifyEmail() {
           </div>
         )}
 
+        {status === 'expired' && (
+          <div className="error-message">
+            <p>{message}</p>
+            <form onSubmit={resendVerification}>
+              <div className="form-group">
+                <input
+                  type="email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                  placeholder="Enter your email"
+                />
+              </div>
+              <button type="submit" className="btn-primary" style={{padding: '12px 30px', cursor: 'pointer'}}>
+                Send New Link
+              </button>
+            </form>
+          </div>
+        )}
+
         {status === 'error' && (
           <div className="error-message">
             <svg width="64" height="64" viewBox="0 0 24 24" fill="none" stroke="currentColor" strokeWidth="2">
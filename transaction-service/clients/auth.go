@@ -0,0 +1,65 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"transaction-service/models"
+)
+
+// AuthClient looks users up through the internal routes of the auth-service
+type AuthClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewAuthClient(baseURL, apiKey string) *AuthClient {
+	return &AuthClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (a *AuthClient) GetUser(userID int) (models.User, error) {
+	return a.getUser("/internal/users/" + strconv.Itoa(userID))
+}
+
+func (a *AuthClient) FindUserByEmail(email string) (models.User, error) {
+	return a.getUser("/internal/users/lookup?email=" + url.QueryEscape(email))
+}
+
+func (a *AuthClient) FindUserByHandle(handle string) (models.User, error) {
+	return a.getUser("/internal/users/lookup?handle=" + url.QueryEscape(handle))
+}
+
+func (a *AuthClient) getUser(path string) (models.User, error) {
+	var user models.User
+
+	req, err := http.NewRequest(http.MethodGet, a.baseURL+path, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("X-Internal-Api-Key", a.apiKey)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return user, fmt.Errorf("auth-service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.NewDecoder(resp.Body).Decode(&user)
+		return user, err
+	case http.StatusNotFound:
+		return user, ErrUserNotFound
+	default:
+		return user, fmt.Errorf("auth-service returned status %d", resp.StatusCode)
+	}
+}
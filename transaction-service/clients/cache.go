@@ -0,0 +1,66 @@
+package clients
+
+import (
+	"sync"
+	"time"
+	"transaction-service/models"
+)
+
+type cachedUser struct {
+	user      models.User
+	expiresAt time.Time
+}
+
+// CachedUserLookup remembers verified users by id for a while so busy senders do not hit the auth-service on every transfer.
+// Unknown and unverified users are never cached, they may register or verify at any moment.
+// Email and handle lookups always reach the auth-service because both can change.
+type CachedUserLookup struct {
+	next    UserLookup
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[int]cachedUser
+}
+
+func NewCachedUserLookup(next UserLookup, ttl time.Duration) *CachedUserLookup {
+	return &CachedUserLookup{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[int]cachedUser),
+	}
+}
+
+func (l *CachedUserLookup) GetUser(userID int) (models.User, error) {
+	l.mu.RLock()
+	entry, found := l.entries[userID]
+	l.mu.RUnlock()
+	if found && time.Now().Before(entry.expiresAt) {
+		return entry.user, nil
+	}
+
+	return l.remember(l.next.GetUser(userID))
+}
+
+func (l *CachedUserLookup) FindUserByEmail(email string) (models.User, error) {
+	return l.remember(l.next.FindUserByEmail(email))
+}
+
+func (l *CachedUserLookup) FindUserByHandle(handle string) (models.User, error) {
+	return l.remember(l.next.FindUserByHandle(handle))
+}
+
+// remember caches a successful lookup under the user id
+func (l *CachedUserLookup) remember(user models.User, err error) (models.User, error) {
+	if err != nil {
+		return user, err
+	}
+
+	l.mu.Lock()
+	if user.IsVerified {
+		l.entries[user.ID] = cachedUser{user: user, expiresAt: time.Now().Add(l.ttl)}
+	} else {
+		delete(l.entries, user.ID)
+	}
+	l.mu.Unlock()
+
+	return user, nil
+}
@@ -0,0 +1,58 @@
+package clients
+
+import (
+	"strings"
+	"sync"
+	"transaction-service/models"
+)
+
+// FakeUserLookup is an in-memory UserLookup for tests
+type FakeUserLookup struct {
+	mu    sync.RWMutex
+	users map[int]models.User
+}
+
+func NewFakeUserLookup(users ...models.User) *FakeUserLookup {
+	fake := &FakeUserLookup{users: make(map[int]models.User)}
+	for _, user := range users {
+		fake.Add(user)
+	}
+	return fake
+}
+
+// Add registers a user with the fake, replacing any user with the same id
+func (f *FakeUserLookup) Add(user models.User) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[user.ID] = user
+}
+
+func (f *FakeUserLookup) GetUser(userID int) (models.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	user, found := f.users[userID]
+	if !found {
+		return user, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *FakeUserLookup) FindUserByEmail(email string) (models.User, error) {
+	return f.find(func(user models.User) bool { return strings.EqualFold(user.Email, email) })
+}
+
+func (f *FakeUserLookup) FindUserByHandle(handle string) (models.User, error) {
+	handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+	return f.find(func(user models.User) bool { return user.Handle != "" && user.Handle == handle })
+}
+
+func (f *FakeUserLookup) find(match func(models.User) bool) (models.User, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, user := range f.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
@@ -0,0 +1,187 @@
+package clients
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"transaction-service/config"
+)
+
+var (
+	ErrUnknownSigningKey = errors.New("unknown signing key")
+	ErrJWKSUnavailable   = errors.New("signing keys unavailable")
+)
+
+// Unknown kids trigger a refetch so rotated keys are picked up early, but not more often than this
+const jwksMinRefetchInterval = 30 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// verificationKey is a public key from the JWKS together with the algorithm it may verify
+type verificationKey struct {
+	alg string
+	key interface{}
+}
+
+// JWKSCache holds the auth-service public signing keys and refreshes them after ttl
+type JWKSCache struct {
+	url        string
+	ttl        time.Duration
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]verificationKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// SigningKeys verifies access tokens in the AuthMiddleware, set up by InitSigningKeys
+var SigningKeys *JWKSCache
+
+// InitSigningKeys points SigningKeys at the JWKS endpoint of the auth-service
+func InitSigningKeys() {
+	ttlSeconds, err := strconv.Atoi(config.GetEnv("JWKS_CACHE_TTL_SECONDS", "300"))
+	if err != nil || ttlSeconds < 1 {
+		ttlSeconds = 300
+	}
+
+	authServiceURL := strings.TrimSuffix(config.GetEnv("AUTH_SERVICE_URL", "http://localhost:8081"), "/")
+	SigningKeys = NewJWKSCache(config.GetEnv("JWKS_URL", authServiceURL+"/.well-known/jwks.json"), time.Duration(ttlSeconds)*time.Second)
+
+	// Warm the cache, a failure is retried on the first request
+	if err := SigningKeys.refresh(); err != nil {
+		log.Println("Failed to fetch JWKS from the auth-service:", err)
+	}
+}
+
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:        url,
+		ttl:        ttl,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Key returns the public key for a kid, checking that the token's alg matches the key
+func (j *JWKSCache) Key(kid, alg string) (interface{}, error) {
+	j.mu.RLock()
+	key, found := j.keys[kid]
+	fresh := time.Since(j.fetchedAt) < j.ttl
+	j.mu.RUnlock()
+
+	if !found || !fresh {
+		if err := j.refresh(); err != nil {
+			// Keep verifying with the previous keys while the auth-service is unreachable
+			if !found {
+				return nil, fmt.Errorf("%w: %v", ErrJWKSUnavailable, err)
+			}
+			log.Println("Failed to refresh JWKS, using cached keys:", err)
+		}
+		j.mu.RLock()
+		key, found = j.keys[kid]
+		j.mu.RUnlock()
+	}
+
+	if !found {
+		return nil, ErrUnknownSigningKey
+	}
+	if key.alg != alg {
+		return nil, fmt.Errorf("unexpected signing method %q for key %q", alg, kid)
+	}
+	return key.key, nil
+}
+
+func (j *JWKSCache) refresh() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Another request may have just fetched while this one waited for the lock,
+	// and neither unknown kids nor an unreachable auth-service may hammer the endpoint
+	if time.Since(j.lastAttempt) < jwksMinRefetchInterval {
+		return j.lastErr
+	}
+	j.lastAttempt = time.Now()
+	j.lastErr = j.fetch()
+	return j.lastErr
+}
+
+func (j *JWKSCache) fetch() error {
+	resp, err := j.httpClient.Get(j.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return err
+	}
+
+	keys := make(map[string]verificationKey, len(document.Keys))
+	for _, k := range document.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("Skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+// publicKey decodes the Ed25519 and RSA keys published by the auth-service
+func (k jwk) publicKey() (verificationKey, error) {
+	switch {
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == "EdDSA":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return verificationKey{}, errors.New("invalid Ed25519 public key")
+		}
+		return verificationKey{alg: k.Alg, key: ed25519.PublicKey(x)}, nil
+
+	case k.Kty == "RSA" && k.Alg == "RS256":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return verificationKey{}, errors.New("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, errors.New("invalid RSA exponent")
+		}
+		return verificationKey{alg: k.Alg, key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %s/%s", k.Kty, k.Alg)
+	}
+}
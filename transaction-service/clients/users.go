@@ -0,0 +1,36 @@
+// Package clients talks to the other services of the application
+package clients
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"transaction-service/config"
+	"transaction-service/models"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+// UserLookup resolves user ids, emails and handles into users
+type UserLookup interface {
+	GetUser(userID int) (models.User, error)
+	FindUserByEmail(email string) (models.User, error)
+	FindUserByHandle(handle string) (models.User, error)
+}
+
+// Users is the lookup used by the handlers, set up by InitUserLookup
+var Users UserLookup
+
+// InitUserLookup points Users at the auth-service with a short lived cache in front
+func InitUserLookup() {
+	ttlSeconds, err := strconv.Atoi(config.GetEnv("USER_CACHE_TTL_SECONDS", "300"))
+	if err != nil || ttlSeconds < 0 {
+		ttlSeconds = 300
+	}
+
+	authClient := NewAuthClient(
+		config.GetEnv("AUTH_SERVICE_URL", "http://localhost:8081"),
+		config.GetEnv("INTERNAL_API_KEY", ""),
+	)
+	Users = NewCachedUserLookup(authClient, time.Duration(ttlSeconds)*time.Second)
+}
@@ -48,7 +48,7 @@ func RunMigrations() {
 		id SERIAL PRIMARY KEY,
 		sender_id INTEGER NOT NULL,
 		receiver_id INTEGER NOT NULL,
-		amount DECIMAL(15, 2) NOT NULL, #15 digits before decimal and 2 digits after decimal total integers 13
+		amount DECIMAL(15, 2) NOT NULL, -- 15 digits in total, 13 before the decimal point and 2 after
 		status VARCHAR(50) DEFAULT 'pending',
 		description TEXT,
 		transaction_type VARCHAR(50) NOT NULL,
@@ -57,14 +57,309 @@ func RunMigrations() {
 	);
 	`
 
-	_, err := DB.Exec(createWalletsTable)
-	if err != nil {
-		log.Fatal("Failed to create wallets table:", err)
+	// Pending transfers hold the sender's funds until the receiver accepts or they expire
+	addTransactionsExpiresAtColumn := `
+	ALTER TABLE transactions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_transactions_pending_expiry ON transactions (expires_at) WHERE status = 'pending';
+	`
+
+	// Refunds point at the transfer they give money back for
+	addTransactionsOriginalColumn := `
+	ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_transaction_id INTEGER REFERENCES transactions(id);
+	CREATE INDEX IF NOT EXISTS idx_transactions_original ON transactions (original_transaction_id);
+	`
+
+	// Indexes backing the keyset pagination of GET /transactions
+	createTransactionsIndexes := `
+	CREATE INDEX IF NOT EXISTS idx_transactions_sender_created ON transactions (sender_id, created_at DESC, id DESC);
+	CREATE INDEX IF NOT EXISTS idx_transactions_receiver_created ON transactions (receiver_id, created_at DESC, id DESC);
+	`
+
+	createIdempotencyKeysTable := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		idempotency_key VARCHAR(255) NOT NULL,
+		request_path VARCHAR(255) NOT NULL,
+		request_hash VARCHAR(64) NOT NULL,
+		response_status INTEGER,
+		response_body TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, idempotency_key)
+	);
+	`
+
+	createLedgerAccountsTable := `
+	CREATE TABLE IF NOT EXISTS ledger_accounts (
+		id SERIAL PRIMARY KEY,
+		code VARCHAR(100) UNIQUE NOT NULL,
+		account_type VARCHAR(50) NOT NULL,
+		user_id INTEGER UNIQUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	createJournalEntriesTable := `
+	CREATE TABLE IF NOT EXISTS journal_entries (
+		id SERIAL PRIMARY KEY,
+		transaction_id INTEGER REFERENCES transactions(id),
+		description TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	// Every journal entry has debit postings and credit postings that sum to the same amount
+	createPostingsTable := `
+	CREATE TABLE IF NOT EXISTS postings (
+		id SERIAL PRIMARY KEY,
+		journal_entry_id INTEGER NOT NULL REFERENCES journal_entries(id),
+		account_id INTEGER NOT NULL REFERENCES ledger_accounts(id),
+		direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings (account_id);
+	`
+
+	// NOT VALID keeps the migration working on databases that already hold a negative balance,
+	// the constraint is still enforced for every new write
+	addWalletBalanceCheck := `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'wallets_balance_non_negative') THEN
+			ALTER TABLE wallets ADD CONSTRAINT wallets_balance_non_negative CHECK (balance >= 0) NOT VALID;
+		END IF;
+	END $$;
+	`
+
+	// Admins can freeze a wallet, every wallet starts out active
+	addWalletsStatusColumn := `
+	ALTER TABLE wallets ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+	`
+
+	// Every admin action on a wallet is recorded with the admin, the reason and what changed
+	createWalletAuditLogTable := `
+	CREATE TABLE IF NOT EXISTS wallet_audit_log (
+		id SERIAL PRIMARY KEY,
+		admin_user_id INTEGER NOT NULL,
+		action VARCHAR(50) NOT NULL,
+		user_id INTEGER NOT NULL,
+		transaction_id INTEGER REFERENCES transactions(id),
+		reason TEXT NOT NULL,
+		details JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_wallet_audit_log_user ON wallet_audit_log (user_id, created_at DESC);
+	`
+
+	// Frozen wallets can receive but not send, closed wallets can do neither
+	addWalletsStatusCheck := `
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'wallets_status_check') THEN
+			ALTER TABLE wallets ADD CONSTRAINT wallets_status_check CHECK (status IN ('active', 'frozen', 'closed'));
+		END IF;
+	END $$;
+	`
+
+	// Outgoing limits per account tier, NULL means unlimited. The defaults are only inserted once,
+	// after that the tiers are managed through the admin API.
+	createLimitTiersTable := `
+	CREATE TABLE IF NOT EXISTS limit_tiers (
+		tier VARCHAR(20) PRIMARY KEY,
+		max_per_transaction DECIMAL(15, 2),
+		daily_amount DECIMAL(15, 2),
+		daily_count INTEGER,
+		monthly_amount DECIMAL(15, 2),
+		monthly_count INTEGER,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO limit_tiers (tier, max_per_transaction, daily_amount, daily_count, monthly_amount, monthly_count) VALUES
+		('basic', 500.00, 1000.00, 10, 5000.00, 100),
+		('standard', 5000.00, 10000.00, 50, 50000.00, 500),
+		('premium', 50000.00, 100000.00, NULL, 1000000.00, NULL)
+	ON CONFLICT (tier) DO NOTHING;
+	`
+
+	// Every wallet belongs to a limit tier
+	addWalletsTierColumn := `
+	ALTER TABLE wallets ADD COLUMN IF NOT EXISTS tier VARCHAR(20) NOT NULL DEFAULT 'standard' REFERENCES limit_tiers(tier);
+	`
+
+	// Scheduled and recurring transfers. Run times are computed in Go, TIMESTAMPTZ keeps them exact
+	// whatever time zone the database session uses.
+	createScheduledTransfersTable := `
+	CREATE TABLE IF NOT EXISTS scheduled_transfers (
+		id SERIAL PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		description TEXT NOT NULL DEFAULT '',
+		frequency VARCHAR(20) NOT NULL,
+		cron_expression VARCHAR(100),
+		start_at TIMESTAMPTZ NOT NULL,
+		end_at TIMESTAMPTZ,
+		next_run_at TIMESTAMPTZ,
+		status VARCHAR(20) NOT NULL DEFAULT 'active',
+		on_insufficient_funds VARCHAR(10) NOT NULL DEFAULT 'skip',
+		max_retries INTEGER NOT NULL DEFAULT 3,
+		retry_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers (next_run_at) WHERE status = 'active';
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_sender ON scheduled_transfers (sender_id, created_at DESC);
+	`
+
+	// One row per execution of a scheduled transfer, successful or not
+	createScheduledTransferRunsTable := `
+	CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+		id SERIAL PRIMARY KEY,
+		scheduled_transfer_id INTEGER NOT NULL REFERENCES scheduled_transfers(id) ON DELETE CASCADE,
+		transaction_id INTEGER REFERENCES transactions(id),
+		status VARCHAR(20) NOT NULL,
+		error TEXT,
+		scheduled_for TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_transfer_runs_schedule ON scheduled_transfer_runs (scheduled_transfer_id, id DESC);
+	`
+
+	// Requests from one user asking another to pay them, transaction_id is the transfer that paid it
+	createPaymentRequestsTable := `
+	CREATE TABLE IF NOT EXISTS payment_requests (
+		id SERIAL PRIMARY KEY,
+		requester_id INTEGER NOT NULL,
+		payer_id INTEGER NOT NULL,
+		amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+		description TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		transaction_id INTEGER REFERENCES transactions(id),
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_payment_requests_payer ON payment_requests (payer_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_payment_requests_requester ON payment_requests (requester_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_payment_requests_expiry ON payment_requests (expires_at) WHERE status = 'pending';
+	`
+
+	// A bill split by its creator, every other participant's share is a payment request pointing back at it
+	createBillSplitsTable := `
+	CREATE TABLE IF NOT EXISTS bill_splits (
+		id SERIAL PRIMARY KEY,
+		creator_id INTEGER NOT NULL,
+		total_amount DECIMAL(15, 2) NOT NULL CHECK (total_amount > 0),
+		description TEXT NOT NULL DEFAULT '',
+		split_type VARCHAR(20) NOT NULL,
+		creator_share DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_bill_splits_creator ON bill_splits (creator_id, created_at DESC);
+	`
+
+	addPaymentRequestsSplitColumn := `
+	ALTER TABLE payment_requests ADD COLUMN IF NOT EXISTS split_id INTEGER REFERENCES bill_splits(id);
+	CREATE INDEX IF NOT EXISTS idx_payment_requests_split ON payment_requests (split_id) WHERE split_id IS NOT NULL;
+	`
+
+	// A batch of transfers from one sender, every transfer it made carries the batch_id
+	createTransferBatchesTable := `
+	CREATE TABLE IF NOT EXISTS transfer_batches (
+		id SERIAL PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		mode VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		total_amount DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+		item_count INTEGER NOT NULL,
+		succeeded_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_transfer_batches_sender ON transfer_batches (sender_id, created_at DESC);
+	`
+
+	// The outcome of every item of a batch in the order it was submitted
+	createTransferBatchItemsTable := `
+	CREATE TABLE IF NOT EXISTS transfer_batch_items (
+		batch_id INTEGER NOT NULL REFERENCES transfer_batches(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		receiver VARCHAR(255) NOT NULL,
+		receiver_id INTEGER,
+		amount DECIMAL(15, 2) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL,
+		transaction_id INTEGER REFERENCES transactions(id),
+		error TEXT,
+		PRIMARY KEY (batch_id, position)
+	);
+	`
+
+	addTransactionsBatchColumn := `
+	ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_id INTEGER REFERENCES transfer_batches(id);
+	CREATE INDEX IF NOT EXISTS idx_transactions_batch ON transactions (batch_id) WHERE batch_id IS NOT NULL;
+	`
+
+	// Bulk payout files uploaded as CSV, kept as uploaded so the file that was checked is the file that is executed
+	createPayoutFilesTable := `
+	CREATE TABLE IF NOT EXISTS payout_files (
+		id SERIAL PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		file_name VARCHAR(255) NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'validated',
+		row_count INTEGER NOT NULL,
+		valid_count INTEGER NOT NULL,
+		total_amount DECIMAL(15, 2) NOT NULL,
+		batch_id INTEGER REFERENCES transfer_batches(id),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		executed_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_payout_files_sender ON payout_files (sender_id, created_at DESC);
+	`
+
+	// Changes to a limit tier are audited too, they concern no single user
+	allowAuditLogWithoutUser := `
+	ALTER TABLE wallet_audit_log ALTER COLUMN user_id DROP NOT NULL;
+	`
+
+	// Migrations run in order, later tables may reference earlier ones
+	migrations := []struct {
+		name  string
+		query string
+	}{
+		{"wallets table", createWalletsTable},
+		{"wallets balance check", addWalletBalanceCheck},
+		{"transactions table", createTransactionsTable},
+		{"transactions indexes", createTransactionsIndexes},
+		{"transactions expires_at column", addTransactionsExpiresAtColumn},
+		{"transactions original_transaction_id column", addTransactionsOriginalColumn},
+		{"idempotency_keys table", createIdempotencyKeysTable},
+		{"ledger_accounts table", createLedgerAccountsTable},
+		{"journal_entries table", createJournalEntriesTable},
+		{"postings table", createPostingsTable},
+		{"wallets status column", addWalletsStatusColumn},
+		{"wallet_audit_log table", createWalletAuditLogTable},
+		{"wallets status check", addWalletsStatusCheck},
+		{"limit_tiers table", createLimitTiersTable},
+		{"wallets tier column", addWalletsTierColumn},
+		{"scheduled_transfers table", createScheduledTransfersTable},
+		{"scheduled_transfer_runs table", createScheduledTransferRunsTable},
+		{"payment_requests table", createPaymentRequestsTable},
+		{"bill_splits table", createBillSplitsTable},
+		{"payment_requests split_id column", addPaymentRequestsSplitColumn},
+		{"transfer_batches table", createTransferBatchesTable},
+		{"transfer_batch_items table", createTransferBatchItemsTable},
+		{"transactions batch_id column", addTransactionsBatchColumn},
+		{"payout_files table", createPayoutFilesTable},
+		{"wallet_audit_log nullable user_id", allowAuditLogWithoutUser},
 	}
 
-	_, err = DB.Exec(createTransactionsTable)
-	if err != nil {
-		log.Fatal("Failed to create transactions table:", err)
+	for _, migration := range migrations {
+		if _, err := DB.Exec(migration.query); err != nil {
+			log.Fatalf("Failed to apply %s migration: %v", migration.name, err)
+		}
 	}
 
 	log.Println("Database migrations completed successfully")
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"transaction-service/clients"
+	"transaction-service/config"
+	"transaction-service/ledger"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLogLimit = 50
+	maxAuditLogLimit     = 200
+)
+
+// AdminGetWallet shows any user's wallet together with its ledger reconciliation
+func AdminGetWallet(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var detail models.WalletDetail
+	err := services.ScanWallet(config.DB.QueryRow(
+		"SELECT "+services.WalletColumns+" FROM wallets WHERE user_id = $1",
+		userID,
+	), &detail.Wallet)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet"})
+		return
+	}
+
+	reconciliation, err := ledger.ReconcileWallet(config.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile wallet"})
+		return
+	}
+	detail.LedgerBalance = reconciliation.LedgerBalance
+	detail.Balanced = reconciliation.Balanced
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// AdminGetTransactions returns any user's transactions, with the same paging and filters as GET /transactions
+func AdminGetTransactions(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := services.ListTransactions(config.DB, userID, filter)
+	if err == services.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// FreezeWallet stops a wallet from being used until it is unfrozen
+func FreezeWallet(c *gin.Context) {
+	setWalletStatus(c, services.WalletFrozen, services.AuditWalletFrozen, "Wallet frozen")
+}
+
+// UnfreezeWallet makes a frozen wallet usable again
+func UnfreezeWallet(c *gin.Context) {
+	setWalletStatus(c, services.WalletActive, services.AuditWalletUnfrozen, "Wallet unfrozen")
+}
+
+// CloseWallet closes an empty wallet for good, it can neither send nor receive money afterwards
+func CloseWallet(c *gin.Context) {
+	setWalletStatus(c, services.WalletClosed, services.AuditWalletClosed, "Wallet closed")
+}
+
+func setWalletStatus(c *gin.Context, status, action, message string) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.AdminActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(int)
+
+	var wallet models.Wallet
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		wallet, err = services.SetWalletStatus(tx, adminID, userID, status, action, req.Reason)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to update wallet status")
+		return
+	}
+
+	log.Printf("Admin %d set wallet of user %d to %s: %s", adminID, userID, status, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"wallet":  wallet,
+	})
+}
+
+// AdjustWallet credits or debits a wallet by hand, for corrections that no regular operation covers
+func AdjustWallet(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.AdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A credit creates the wallet if needed, so make sure it belongs to a real user
+	if _, err := clients.Users.GetUser(userID); err != nil {
+		if errors.Is(err, clients.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		log.Printf("Failed to look up user %d: %v", userID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify user"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(int)
+
+	var transaction models.Transaction
+	var newBalance models.Money
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		transaction, newBalance, err = services.Adjust(tx, adminID, userID, req.Direction, req.Amount, req.Reason)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to adjust wallet")
+		return
+	}
+
+	log.Printf("Admin %d adjusted wallet of user %d (%s %s): %s", adminID, userID, req.Direction, req.Amount, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Wallet adjusted",
+		"transaction": transaction,
+		"new_balance": newBalance,
+	})
+}
+
+// GetWalletAuditLog lists the newest admin actions on wallets, optionally for one user
+func GetWalletAuditLog(c *gin.Context) {
+	userID := 0
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a positive number"})
+			return
+		}
+		userID = id
+	}
+
+	limit := defaultAuditLogLimit
+	if value := c.Query("limit"); value != "" {
+		l, err := strconv.Atoi(value)
+		if err != nil || l < 1 || l > maxAuditLogLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and " + strconv.Itoa(maxAuditLogLimit)})
+			return
+		}
+		limit = l
+	}
+
+	entries, err := services.ListAuditLog(config.DB, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// parseUserIDParam reads the :user_id path parameter and answers 404 when it is not a user id
+func parseUserIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil || userID < 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchTransfer pays several receivers in one call, either all of them or in best effort mode as many as possible
+func BatchTransfer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BatchTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := services.ResolveBatchItems(req.Items)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to verify receivers")
+		return
+	}
+
+	var batch models.TransferBatch
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		batch, err = services.ExecuteBatch(tx, userID.(int), req.Mode, items)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Batch transfer failed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetTransferBatch returns a batch with the outcome of each of its transfers
+func GetTransferBatch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer batch not found"})
+		return
+	}
+
+	batch, err := services.GetTransferBatch(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to fetch transfer batch")
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithServiceError maps the typed errors from services to HTTP responses,
+// anything unexpected is logged and reported with the fallback message
+func respondWithServiceError(c *gin.Context, err error, fallback string) {
+	var limitErr *services.LimitExceededError
+	var batchErr *services.BatchRejectedError
+	switch {
+	case errors.As(err, &limitErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": limitErr.Error(), "limit": limitErr})
+	case errors.As(err, &batchErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Batch rejected, no transfers were made", "items": batchErr.Items})
+	case errors.Is(err, services.ErrTierNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown limit tier"})
+	case errors.Is(err, services.ErrInsufficientFunds):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+	case errors.Is(err, services.ErrWalletNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+	case errors.Is(err, services.ErrSelfTransfer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to yourself"})
+	case errors.Is(err, services.ErrReceiverRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of receiver_id, receiver_email or receiver_handle is required"})
+	case errors.Is(err, services.ErrReceiverNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiver not found"})
+	case errors.Is(err, services.ErrReceiverNotVerified):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receiver has not verified their email"})
+	case errors.Is(err, services.ErrUserLookupFailed):
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify receiver"})
+	case errors.Is(err, services.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	case errors.Is(err, services.ErrTransferNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer is no longer pending"})
+	case errors.Is(err, services.ErrTransferExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Transfer has expired, the funds are being returned to the sender"})
+	case errors.Is(err, services.ErrNotRefundable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only completed transfers you received can be refunded"})
+	case errors.Is(err, services.ErrRefundExceedsRemaining):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund exceeds the amount left to refund"})
+	case errors.Is(err, services.ErrWalletFrozen):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wallet is frozen, it can receive money but not send it"})
+	case errors.Is(err, services.ErrWalletClosed):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wallet is closed"})
+	case errors.Is(err, services.ErrReceiverWalletClosed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receiver's wallet is closed"})
+	case errors.Is(err, services.ErrReceiverDisabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receiver's account is disabled"})
+	case errors.Is(err, services.ErrWalletStatusUnchanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Wallet already has this status"})
+	case errors.Is(err, services.ErrWalletNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only an empty wallet can be closed"})
+	case errors.Is(err, services.ErrWalletHasPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Wallet has pending transfers, wait until they are settled"})
+	case errors.Is(err, services.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled transfer not found"})
+	case errors.Is(err, services.ErrScheduleFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled transfer has already finished"})
+	case errors.Is(err, services.ErrScheduleStatusUnchanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled transfer already has this status"})
+	case errors.Is(err, services.ErrSelfPaymentRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot request money from yourself"})
+	case errors.Is(err, services.ErrPayerRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of payer_id, payer_email or payer_handle is required"})
+	case errors.Is(err, services.ErrPayerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payer not found"})
+	case errors.Is(err, services.ErrPayerNotVerified):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payer has not verified their email"})
+	case errors.Is(err, services.ErrPayerDisabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payer's account is disabled"})
+	case errors.Is(err, services.ErrPaymentRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+	case errors.Is(err, services.ErrPaymentRequestNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment request is no longer pending"})
+	case errors.Is(err, services.ErrPaymentRequestExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Payment request has expired"})
+	case errors.Is(err, services.ErrInvalidSplit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBillSplitNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill split not found"})
+	case errors.Is(err, services.ErrBillSplitNotOpen):
+		c.JSON(http.StatusConflict, gin.H{"error": "Bill split is no longer open"})
+	case errors.Is(err, services.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer batch not found"})
+	case errors.Is(err, services.ErrInvalidPayoutFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPayoutNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+	case errors.Is(err, services.ErrPayoutAlreadyExecuted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payout has already been executed"})
+	case errors.Is(err, services.ErrPayoutNotExecuted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payout has not been executed yet"})
+	case errors.Is(err, services.ErrConcurrentUpdate):
+		c.JSON(http.StatusConflict, gin.H{"error": "Wallet is busy with another transaction, please retry"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"regexp"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tierNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,20}$`)
+
+// GetWalletLimits shows the limits of the user's tier and how much of them is used
+func GetWalletLimits(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	usage, err := services.GetLimitUsage(config.DB, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch limits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// ListLimitTiers returns every limit tier
+func ListLimitTiers(c *gin.Context) {
+	tiers, err := services.ListLimitTiers(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch limit tiers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tiers": tiers})
+}
+
+// SaveLimitTier creates a tier or replaces its limits, the new limits apply to the next payment
+func SaveLimitTier(c *gin.Context) {
+	name := c.Param("tier")
+	if !tierNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tier names are 1 to 20 lowercase letters, digits or underscores"})
+		return
+	}
+
+	var req models.UpdateLimitTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(int)
+
+	var tier models.LimitTier
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		tier, err = services.SaveLimitTier(tx, adminID, name, req)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save limit tier"})
+		return
+	}
+
+	log.Printf("Admin %d saved limit tier %s: %s", adminID, name, req.Reason)
+
+	c.JSON(http.StatusOK, tier)
+}
+
+// SetWalletTier moves a user's wallet to another limit tier
+func SetWalletTier(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(int)
+
+	var wallet models.Wallet
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		wallet, err = services.SetWalletTier(tx, adminID, userID, req.Tier, req.Reason)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to change limit tier")
+		return
+	}
+
+	log.Printf("Admin %d moved wallet of user %d to tier %s: %s", adminID, userID, req.Tier, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Limit tier changed",
+		"wallet":  wallet,
+	})
+}
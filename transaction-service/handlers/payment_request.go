@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePaymentRequest asks another verified user to pay the caller
+func CreatePaymentRequest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.PaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payer, err := services.ResolvePayer(req.PayerID, req.PayerEmail, req.PayerHandle)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to verify payer")
+		return
+	}
+
+	paymentRequest, err := services.CreatePaymentRequest(config.DB, userID.(int), payer.ID, req.Amount, req.Description, req.ExpiresInHours)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to create payment request")
+		return
+	}
+
+	c.JSON(http.StatusCreated, paymentRequest)
+}
+
+// GetIncomingPaymentRequests lists the requests the user was asked to pay, optionally filtered by ?status=
+func GetIncomingPaymentRequests(c *gin.Context) {
+	listPaymentRequests(c, true)
+}
+
+// GetOutgoingPaymentRequests lists the requests the user sent, optionally filtered by ?status=
+func GetOutgoingPaymentRequests(c *gin.Context) {
+	listPaymentRequests(c, false)
+}
+
+func listPaymentRequests(c *gin.Context, incoming bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", services.PaymentRequestPending, services.PaymentRequestPaid, services.PaymentRequestDeclined,
+		services.PaymentRequestCancelled, services.PaymentRequestExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of pending, paid, declined, cancelled or expired"})
+		return
+	}
+
+	requests, err := services.ListPaymentRequests(config.DB, userID.(int), incoming, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payment requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment_requests": requests})
+}
+
+// GetPaymentRequest returns a payment request the user sent or was asked to pay
+func GetPaymentRequest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+		return
+	}
+
+	paymentRequest, err := services.GetPaymentRequest(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to fetch payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, paymentRequest)
+}
+
+// PayPaymentRequest pays a pending request with a transfer to the requester
+func PayPaymentRequest(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+		return
+	}
+
+	var paymentRequest models.PaymentRequest
+	var transaction models.Transaction
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		paymentRequest, transaction, err = services.PayPaymentRequest(tx, id, userID.(int))
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to pay payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Payment request paid successfully",
+		"payment_request": paymentRequest,
+		"transaction":     transaction,
+	})
+}
+
+// DeclinePaymentRequest lets the payer refuse a pending request
+func DeclinePaymentRequest(c *gin.Context) {
+	answerPaymentRequest(c, services.DeclinePaymentRequest)
+}
+
+// CancelPaymentRequest lets the requester withdraw a pending request
+func CancelPaymentRequest(c *gin.Context) {
+	answerPaymentRequest(c, services.CancelPaymentRequest)
+}
+
+func answerPaymentRequest(c *gin.Context, action func(tx *sql.Tx, id, userID int) (models.PaymentRequest, error)) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+		return
+	}
+
+	var paymentRequest models.PaymentRequest
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		paymentRequest, err = action(tx, id, userID.(int))
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to update payment request")
+		return
+	}
+
+	c.JSON(http.StatusOK, paymentRequest)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Large enough for the 500 rows a payout may have
+const maxPayoutFileBytes = 1 << 20
+
+// DryRunPayout checks an uploaded payout CSV without moving money. The file is sent as the multipart field
+// "file" or as a text/csv body. The response lists every row with its error, if any, and the total to be paid.
+func DryRunPayout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileName, content, err := readPayoutFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := services.CreatePayout(config.DB, userID.(int), fileName, content)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to check payout file")
+		return
+	}
+
+	c.JSON(http.StatusCreated, payout)
+}
+
+func readPayoutFile(c *gin.Context) (string, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPayoutFileBytes)
+
+	var reader io.Reader
+	fileName := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return "", "", fmt.Errorf("a CSV file of at most %d bytes is required in the file field", maxPayoutFileBytes)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return "", "", err
+		}
+		defer file.Close()
+		reader = file
+		fileName = filepath.Base(header.Filename)
+	} else {
+		reader = c.Request.Body
+	}
+
+	content, err := io.ReadAll(io.LimitReader(reader, maxPayoutFileBytes+1))
+	if err != nil || len(content) > maxPayoutFileBytes {
+		return "", "", fmt.Errorf("the payout file must be at most %d bytes", maxPayoutFileBytes)
+	}
+	return fileName, string(content), nil
+}
+
+// ExecutePayout pays a dry-run payout file as a batch transfer. Receivers are checked again first,
+// the mode decides whether a failing row stops the whole file.
+func ExecutePayout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	}
+
+	// The body is optional, atomic is the default mode
+	var req models.ExecutePayoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	items, err := services.PreparePayoutExecution(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to execute payout")
+		return
+	}
+
+	var batch models.TransferBatch
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		batch, err = services.ExecutePayout(tx, id, userID.(int), req.Mode, items)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to execute payout")
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetPayoutResult downloads the outcome of an executed payout as CSV, one line per row of the uploaded file
+// with its transaction id or error
+func GetPayoutResult(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	}
+
+	batch, err := services.GetPayoutBatch(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to fetch payout result")
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="payout-%d-result.csv"`, id))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"row", "receiver", "amount", "description", "status", "transaction_id", "error"})
+	for _, item := range batch.Items {
+		transactionID := ""
+		if item.TransactionID != nil {
+			transactionID = strconv.Itoa(*item.TransactionID)
+		}
+		writer.Write([]string{strconv.Itoa(item.Position), csvCell(item.Receiver), item.Amount.String(), csvCell(item.Description), item.Status, transactionID, csvCell(item.Error)})
+	}
+	writer.Flush()
+}
+
+// csvCell keeps uploaded values from running as formulas when the result file is opened in a spreadsheet
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateScheduledTransfer schedules a one-off or recurring transfer to a verified user
+func CreateScheduledTransfer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ScheduledTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	receiver, err := services.ResolveReceiver(req.ReceiverID, req.ReceiverEmail, req.ReceiverHandle)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to verify receiver")
+		return
+	}
+
+	scheduled, err := services.CreateScheduledTransfer(config.DB, userID.(int), receiver.ID, req)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to schedule transfer")
+		return
+	}
+
+	c.JSON(http.StatusCreated, scheduled)
+}
+
+// GetScheduledTransfers lists the user's scheduled transfers
+func GetScheduledTransfers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	schedules, err := services.ListScheduledTransfers(config.DB, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scheduled_transfers": schedules})
+}
+
+// GetScheduledTransfer returns a scheduled transfer with the history of its latest runs
+func GetScheduledTransfer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled transfer not found"})
+		return
+	}
+
+	scheduled, err := services.GetScheduledTransfer(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to fetch scheduled transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduled)
+}
+
+// UpdateScheduledTransfer changes the amount, description, schedule or failure policy of a scheduled transfer
+func UpdateScheduledTransfer(c *gin.Context) {
+	var req models.UpdateScheduledTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	changeScheduledTransfer(c, func(tx *sql.Tx, id, userID int) (models.ScheduledTransfer, error) {
+		return services.UpdateScheduledTransfer(tx, id, userID, req)
+	})
+}
+
+// PauseScheduledTransfer stops a scheduled transfer from running until it is resumed
+func PauseScheduledTransfer(c *gin.Context) {
+	changeScheduledTransferStatus(c, services.SchedulePaused)
+}
+
+// ResumeScheduledTransfer lets a paused scheduled transfer run again from its next occurrence
+func ResumeScheduledTransfer(c *gin.Context) {
+	changeScheduledTransferStatus(c, services.ScheduleActive)
+}
+
+// CancelScheduledTransfer ends a scheduled transfer for good, its run history is kept
+func CancelScheduledTransfer(c *gin.Context) {
+	changeScheduledTransferStatus(c, services.ScheduleCancelled)
+}
+
+func changeScheduledTransferStatus(c *gin.Context, status string) {
+	changeScheduledTransfer(c, func(tx *sql.Tx, id, userID int) (models.ScheduledTransfer, error) {
+		return services.SetScheduledTransferStatus(tx, id, userID, status)
+	})
+}
+
+func changeScheduledTransfer(c *gin.Context, change func(tx *sql.Tx, id, userID int) (models.ScheduledTransfer, error)) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled transfer not found"})
+		return
+	}
+
+	var scheduled models.ScheduledTransfer
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		scheduled, err = change(tx, id, userID.(int))
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to update scheduled transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduled)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBillSplit splits a bill between participants and sends each of them a payment request for their share
+func CreateBillSplit(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.BillSplitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check the shares before looking anyone up
+	if _, err := services.AllocateShares(req.TotalAmount, req.SplitType, req.Participants); err != nil {
+		respondWithServiceError(c, err, "Failed to split bill")
+		return
+	}
+
+	participantIDs, err := services.ResolveSplitParticipants(userID.(int), req.Participants)
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to verify participants")
+		return
+	}
+
+	var split models.BillSplit
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		split, err = services.CreateBillSplit(tx, userID.(int), participantIDs, req)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to split bill")
+		return
+	}
+
+	c.JSON(http.StatusCreated, split)
+}
+
+// GetBillSplits lists the splits the user created
+func GetBillSplits(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	splits, err := services.ListBillSplits(config.DB, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bill splits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bill_splits": splits})
+}
+
+// GetBillSplit returns a split with who has settled their share, to its creator and its participants
+func GetBillSplit(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill split not found"})
+		return
+	}
+
+	split, err := services.GetBillSplit(config.DB, id, userID.(int))
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to fetch bill split")
+		return
+	}
+
+	c.JSON(http.StatusOK, split)
+}
+
+// CancelBillSplit withdraws the payment requests of a split that are still pending
+func CancelBillSplit(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill split not found"})
+		return
+	}
+
+	var split models.BillSplit
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		split, err = services.CancelBillSplit(tx, id, userID.(int))
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to cancel bill split")
+		return
+	}
+
+	c.JSON(http.StatusOK, split)
+}
@@ -2,9 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 	"transaction-service/config"
 	"transaction-service/models"
+	"transaction-service/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,97 +30,84 @@ func Transfer(c *gin.Context) {
 
 	senderID := userID.(int)
 
-	// Prevent self-transfer
-	if senderID == req.ReceiverID {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to yourself"})
-		return
-	}
-
-	// Start transaction
-	tx, err := config.DB.Begin()
+	// Resolve the receiver and make sure it is a real, verified user before moving any money
+	receiver, err := services.ResolveReceiver(req.ReceiverID, req.ReceiverEmail, req.ReceiverHandle)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		respondWithServiceError(c, err, "Failed to verify receiver")
 		return
 	}
-	defer tx.Rollback()
 
-	// Check sender balance
-	var senderBalance float64
-	err = tx.QueryRow("SELECT balance FROM wallets WHERE user_id = $1 FOR UPDATE", senderID).Scan(&senderBalance)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Sender wallet not found"})
+	// Prevent self-transfer
+	if senderID == receiver.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot transfer to yourself"})
 		return
 	}
+
+	// Run the transfer in a database transaction, retried on deadlocks and serialization failures
+	var transaction models.Transaction
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		transaction, err = services.SendTransfer(tx, senderID, receiver.ID, req.Amount, req.Description, req.RequireAcceptance)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sender wallet"})
+		respondWithServiceError(c, err, "Failed to complete transfer")
 		return
 	}
 
-	//validate sender balance below the current balance
-	if senderBalance < req.Amount {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
-		return
+	message := "Transfer completed successfully"
+	if req.RequireAcceptance {
+		message = "Transfer is waiting for the receiver to accept it"
 	}
 
-	// Check if receiver wallet exists, create if not
-	var receiverWalletExists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM wallets WHERE user_id = $1)", req.ReceiverID).Scan(&receiverWalletExists)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check receiver wallet"})
-		return
-	}
+	c.JSON(http.StatusOK, models.TransactionResponse{
+		Transaction: transaction,
+		Message:     message,
+	})
+}
 
-	if !receiverWalletExists {
-		_, err = tx.Exec("INSERT INTO wallets (user_id, balance) VALUES ($1, 0.00)", req.ReceiverID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receiver wallet"})
-			return
-		}
-	}
+// AcceptTransfer lets the receiver of a pending transfer take the held funds
+func AcceptTransfer(c *gin.Context) {
+	respondToPendingTransfer(c, services.AcceptPendingTransfer, "Transfer accepted successfully")
+}
 
-	// Deduct from sender
-	_, err = tx.Exec("UPDATE wallets SET balance = balance - $1, updated_at = NOW() WHERE user_id = $2", req.Amount, senderID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deduct from sender"})
+// DeclineTransfer lets the receiver of a pending transfer send the held funds back
+func DeclineTransfer(c *gin.Context) {
+	respondToPendingTransfer(c, services.DeclinePendingTransfer, "Transfer declined, funds returned to sender")
+}
+
+func respondToPendingTransfer(c *gin.Context, action func(tx *sql.Tx, transactionID, receiverID int) (models.Transaction, error), message string) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Add the new fund to receiver
-	_, err = tx.Exec("UPDATE wallets SET balance = balance + $1, updated_at = NOW() WHERE user_id = $2", req.Amount, req.ReceiverID)
+	transactionID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to receiver"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
 		return
 	}
 
-	// Transaction details records to transactions table
 	var transaction models.Transaction
-	err = tx.QueryRow(
-		`INSERT INTO transactions (sender_id, receiver_id, amount, status, transaction_type, description) 
-		 VALUES ($1, $2, $3, $4, $5, $6) 
-		 RETURNING id, sender_id, receiver_id, amount, status, description, transaction_type, created_at, updated_at`,
-		senderID, req.ReceiverID, req.Amount, "completed", "transfer", req.Description,
-	).Scan(
-		&transaction.ID, &transaction.SenderID, &transaction.ReceiverID,
-		&transaction.Amount, &transaction.Status, &transaction.Description,
-		&transaction.TransactionType, &transaction.CreatedAt, &transaction.UpdatedAt,
-	)
-
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		transaction, err = action(tx, transactionID, userID.(int))
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
-		return
-	}
-
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		respondWithServiceError(c, err, "Failed to update transfer")
 		return
 	}
 
 	c.JSON(http.StatusOK, models.TransactionResponse{
 		Transaction: transaction,
-		Message:     "Transfer completed successfully",
+		Message:     message,
 	})
 }
 
+// GetTransactions returns the user's transactions one page at a time.
+// Query parameters: limit, cursor, transaction_type, status, counterparty_id, min_amount, max_amount, from, to
 func GetTransactions(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -122,39 +115,96 @@ func GetTransactions(c *gin.Context) {
 		return
 	}
 
-	rows, err := config.DB.Query(
-		`SELECT id, sender_id, receiver_id, amount, status, description, transaction_type, created_at, updated_at 
-		 FROM transactions 
-		 WHERE sender_id = $1 OR receiver_id = $1 
-		 ORDER BY created_at DESC`,
-		userID,
-	)
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := services.ListTransactions(config.DB, userID.(int), filter)
+	if err == services.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
 		return
 	}
-	defer rows.Close()
+	services.AddCounterparties(userID.(int), page.Transactions)
 
-	var transactions []models.Transaction
-	for rows.Next() {
-		var t models.Transaction
-		err := rows.Scan(
-			&t.ID, &t.SenderID, &t.ReceiverID, &t.Amount,
-			&t.Status, &t.Description, &t.TransactionType,
-			&t.CreatedAt, &t.UpdatedAt,
-		)
+	c.JSON(http.StatusOK, page)
+}
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+// parseTransactionFilter reads the pagination and filter query parameters of GET /transactions
+func parseTransactionFilter(c *gin.Context) (models.TransactionFilter, error) {
+	filter := models.TransactionFilter{
+		TransactionType: c.Query("transaction_type"),
+		Status:          c.Query("status"),
+		Cursor:          c.Query("cursor"),
+		Limit:           defaultTransactionsLimit,
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTransactionsLimit {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxTransactionsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if value := c.Query("counterparty_id"); value != "" {
+		counterpartyID, err := strconv.Atoi(value)
+		if err != nil || counterpartyID < 1 {
+			return filter, errors.New("counterparty_id must be a positive number")
+		}
+		filter.CounterpartyID = counterpartyID
+	}
+
+	for param, target := range map[string]**models.Money{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			amount, err := models.ParseMoney(value)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %v", param, err)
+			}
+			*target = &amount
+		}
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, _, err := parseDateParam(value)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan transaction"})
-			return
+			return filter, errors.New("from must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
 		}
-		transactions = append(transactions, t)
+		filter.From = &from
 	}
 
-	if transactions == nil {
-		transactions = []models.Transaction{}
+	if value := c.Query("to"); value != "" {
+		to, dateOnly, err := parseDateParam(value)
+		if err != nil {
+			return filter, errors.New("to must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+		}
+		// A plain date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	return filter, nil
+}
+
+// parseDateParam accepts either a plain date, taken as midnight UTC, or an RFC 3339 timestamp and reports which one it got
+func parseDateParam(value string) (time.Time, bool, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	return timestamp.UTC(), false, err
 }
 
 func GetTransactionByID(c *gin.Context) {
@@ -167,16 +217,12 @@ func GetTransactionByID(c *gin.Context) {
 	transactionID := c.Param("id")
 
 	var transaction models.Transaction
-	err := config.DB.QueryRow(
-		`SELECT id, sender_id, receiver_id, amount, status, description, transaction_type, created_at, updated_at 
-		 FROM transactions 
+	err := services.ScanTransaction(config.DB.QueryRow(
+		`SELECT `+services.TransactionColumns+`
+		 FROM transactions
 		 WHERE id = $1 AND (sender_id = $2 OR receiver_id = $2)`,
 		transactionID, userID,
-	).Scan(
-		&transaction.ID, &transaction.SenderID, &transaction.ReceiverID,
-		&transaction.Amount, &transaction.Status, &transaction.Description,
-		&transaction.TransactionType, &transaction.CreatedAt, &transaction.UpdatedAt,
-	)
+	), &transaction)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
@@ -187,5 +233,55 @@ func GetTransactionByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	// Show how much of the transaction has been given back and by which refunds
+	detail := models.TransactionDetail{Transaction: transaction}
+	detail.Refunds, err = services.ListRefunds(config.DB, transaction.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+	for _, refund := range detail.Refunds {
+		if refund.Status == "completed" {
+			detail.RefundedAmount += refund.Amount
+		}
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// RefundTransaction gives all or part of a received transfer back to its sender
+func RefundTransaction(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	var req models.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var refund models.Transaction
+	err = services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		refund, err = services.Refund(tx, transactionID, userID.(int), req.Amount, req.Reason)
+		return err
+	})
+	if err != nil {
+		respondWithServiceError(c, err, "Failed to refund transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TransactionResponse{
+		Transaction: refund,
+		Message:     "Refund completed successfully",
+	})
 }
@@ -5,7 +5,9 @@ import (
 	"log"
 	"net/http"
 	"transaction-service/config"
+	"transaction-service/ledger"
 	"transaction-service/models"
+	"transaction-service/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,20 +22,20 @@ func GetWallet(c *gin.Context) {
 	log.Printf("GetWallet called for user_id: %v (type: %T)", userID, userID)
 
 	var wallet models.Wallet
-	err := config.DB.QueryRow(
-		"SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE user_id = $1",
+	err := services.ScanWallet(config.DB.QueryRow(
+		"SELECT "+services.WalletColumns+" FROM wallets WHERE user_id = $1",
 		userID,
-	).Scan(&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.CreatedAt, &wallet.UpdatedAt)
+	), &wallet)
 
 	log.Printf("Wallet fetch result - error: %v, wallet: %+v", err, wallet)
 
 	if err == sql.ErrNoRows {
 		log.Printf("No wallet found for user_id: %v, creating new wallet", userID)
 		// Create wallet if doesn't exist
-		err = config.DB.QueryRow(
-			"INSERT INTO wallets (user_id, balance) VALUES ($1, 0.00) RETURNING id, user_id, balance, created_at, updated_at",
+		err = services.ScanWallet(config.DB.QueryRow(
+			"INSERT INTO wallets (user_id, balance) VALUES ($1, 0.00) RETURNING "+services.WalletColumns,
 			userID,
-		).Scan(&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.CreatedAt, &wallet.UpdatedAt)
+		), &wallet)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wallet"})
@@ -61,53 +63,21 @@ func AddFunds(c *gin.Context) {
 		return
 	}
 
-	log.Printf("AddFunds called - user_id: %v, amount: %f", userID, req.Amount)
-
-	// Start transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-		return
-	}
-	defer tx.Rollback() // Rollback transaction on error
-
-	// Update wallet balance
-	var newBalance float64
-	err = tx.QueryRow(
-		"UPDATE wallets SET balance = balance + $1, updated_at = NOW() WHERE user_id = $2 RETURNING balance",
-		req.Amount, userID,
-	).Scan(&newBalance)
-
-	if err == sql.ErrNoRows {
-		// Create wallet if doesn't exist
-		err = tx.QueryRow(
-			"INSERT INTO wallets (user_id, balance) VALUES ($1, $2) RETURNING balance",
-			userID, req.Amount,
-		).Scan(&newBalance) // Scan new balance into variable
-	}
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update wallet"})
-		return
-	}
-
-	// Record transaction
-	_, err = tx.Exec(
-		"INSERT INTO transactions (sender_id, receiver_id, amount, status, transaction_type, description) VALUES ($1, $2, $3, $4, $5, $6)",
-		userID, userID, req.Amount, "completed", "deposit", "Added funds to wallet",
-	)
+	log.Printf("AddFunds called - user_id: %v, amount: %s", userID, req.Amount)
 
+	// Credit the wallet in a database transaction, the wallet is created if it does not exist
+	var newBalance models.Money
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		newBalance, err = services.Deposit(tx, userID.(int), req.Amount)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
+		respondWithServiceError(c, err, "Failed to update wallet")
 		return
 	}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
-
-	log.Printf("Funds added successfully - user_id: %v, new_balance: %f", userID, newBalance)
+	log.Printf("Funds added successfully - user_id: %v, new_balance: %s", userID, newBalance)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":     "Funds added successfully",
@@ -128,57 +98,55 @@ func WithdrawFunds(c *gin.Context) {
 		return
 	}
 
-	// Check current balance
-	var currentBalance float64
-	err := config.DB.QueryRow("SELECT balance FROM wallets WHERE user_id = $1", userID).Scan(&currentBalance)
+	// Debit the wallet in a database transaction, the balance check happens in the same conditional update
+	var newBalance models.Money
+	err := services.RunInTx(config.DB, func(tx *sql.Tx) error {
+		var err error
+		newBalance, err = services.Withdraw(tx, userID.(int), req.Amount)
+		return err
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet"})
+		respondWithServiceError(c, err, "Failed to update wallet")
 		return
 	}
 
-	if currentBalance < req.Amount {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient funds"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Funds withdrawn successfully",
+		"new_balance": newBalance,
+	})
+}
+
+// ReconcileWallet reports whether the wallet balance matches the balance derived from the ledger
+func ReconcileWallet(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Start transaction
-	tx, err := config.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+	result, err := ledger.ReconcileWallet(config.DB, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
 		return
 	}
-	defer tx.Rollback()
-
-	// Update wallet balance
-	var newBalance float64
-	err = tx.QueryRow(
-		"UPDATE wallets SET balance = balance - $1, updated_at = NOW() WHERE user_id = $2 RETURNING balance",
-		req.Amount, userID,
-	).Scan(&newBalance)
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update wallet"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile wallet"})
 		return
 	}
 
-	// Record transaction
-	_, err = tx.Exec(
-		"INSERT INTO transactions (sender_id, receiver_id, amount, status, transaction_type, description) VALUES ($1, $2, $3, $4, $5, $6)",
-		userID, userID, req.Amount, "completed", "withdrawal", "Withdrew funds from wallet",
-	)
+	c.JSON(http.StatusOK, result)
+}
 
+// ReconcileAllWallets lists every wallet whose balance disagrees with the ledger
+func ReconcileAllWallets(c *gin.Context) {
+	mismatches, err := ledger.Reconcile(config.DB)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile wallets"})
 		return
 	}
-
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+	if mismatches == nil {
+		mismatches = []models.WalletReconciliation{}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "Funds withdrawn successfully",
-		"new_balance": newBalance,
-	})
+	c.JSON(http.StatusOK, gin.H{"mismatches": mismatches})
 }
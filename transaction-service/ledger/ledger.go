@@ -0,0 +1,233 @@
+// Package ledger records every money movement as a balanced double-entry journal.
+// Wallet accounts and system accounts carry a balance of credits minus debits,
+// so the sum over all accounts is always zero and a wallet's ledger balance must equal wallets.balance.
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"transaction-service/models"
+)
+
+// System accounts on the other side of wallet postings
+const (
+	ExternalFunding  = "external_funding"   // deposits are debited here
+	PayoutClearing   = "payout_clearing"    // withdrawals are credited here
+	OpeningBalance   = "opening_balance"    // balances that existed before the ledger
+	PendingTransfers = "pending_transfers"  // funds held for transfers awaiting acceptance
+	Adjustments      = "manual_adjustments" // balance corrections made by admins
+)
+
+const (
+	accountTypeWallet = "wallet"
+	accountTypeSystem = "system"
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry debits and credits do not balance")
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// WalletAccount returns the ledger account of a user's wallet, creating it on first use
+func WalletAccount(tx *sql.Tx, userID int) (int, error) {
+	return ensureAccount(tx, fmt.Sprintf("wallet:%d", userID), accountTypeWallet, userID)
+}
+
+// SystemAccount returns the ledger account for one of the system account codes
+func SystemAccount(tx *sql.Tx, code string) (int, error) {
+	return ensureAccount(tx, code, accountTypeSystem, 0)
+}
+
+func ensureAccount(tx *sql.Tx, code, accountType string, userID int) (int, error) {
+	_, err := tx.Exec(
+		`INSERT INTO ledger_accounts (code, account_type, user_id)
+		 VALUES ($1, $2, NULLIF($3, 0))
+		 ON CONFLICT (code) DO NOTHING`,
+		code, accountType, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var accountID int
+	err = tx.QueryRow("SELECT id FROM ledger_accounts WHERE code = $1", code).Scan(&accountID)
+	return accountID, err
+}
+
+// Record writes a journal entry with its postings after checking that debits equal credits.
+// transactionID links the entry to a row in transactions, 0 means no related transaction.
+func Record(tx *sql.Tx, transactionID int, description string, postings []models.Posting) error {
+	var debits, credits models.Money
+	for _, posting := range postings {
+		if posting.Amount <= 0 {
+			return fmt.Errorf("posting amount must be positive, got %s", posting.Amount)
+		}
+		switch posting.Direction {
+		case models.Debit:
+			debits += posting.Amount
+		case models.Credit:
+			credits += posting.Amount
+		default:
+			return fmt.Errorf("unknown posting direction %q", posting.Direction)
+		}
+	}
+	if len(postings) < 2 || debits != credits {
+		return ErrUnbalancedEntry
+	}
+
+	var journalEntryID int
+	err := tx.QueryRow(
+		"INSERT INTO journal_entries (transaction_id, description) VALUES (NULLIF($1, 0), $2) RETURNING id",
+		transactionID, description,
+	).Scan(&journalEntryID)
+	if err != nil {
+		return err
+	}
+
+	for _, posting := range postings {
+		_, err = tx.Exec(
+			"INSERT INTO postings (journal_entry_id, account_id, direction, amount) VALUES ($1, $2, $3, $4)",
+			journalEntryID, posting.AccountID, posting.Direction, posting.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move records the common two legged entry: debit one account and credit another by the same amount
+func Move(tx *sql.Tx, transactionID int, description string, debitAccountID, creditAccountID int, amount models.Money) error {
+	return Record(tx, transactionID, description, []models.Posting{
+		{AccountID: debitAccountID, Direction: models.Debit, Amount: amount},
+		{AccountID: creditAccountID, Direction: models.Credit, Amount: amount},
+	})
+}
+
+// AccountBalance derives the balance of an account from its postings
+func AccountBalance(q queryer, accountID int) (models.Money, error) {
+	var balance models.Money
+	err := q.QueryRow(
+		`SELECT COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)
+		 FROM postings
+		 WHERE account_id = $1`,
+		accountID,
+	).Scan(&balance)
+	return balance, err
+}
+
+// ReconcileWallet compares wallets.balance of a user with the balance derived from the ledger
+func ReconcileWallet(q queryer, userID int) (models.WalletReconciliation, error) {
+	result := models.WalletReconciliation{UserID: userID}
+	err := q.QueryRow(
+		`SELECT w.balance, COALESCE(SUM(CASE WHEN p.direction = 'credit' THEN p.amount ELSE -p.amount END), 0)
+		 FROM wallets w
+		 LEFT JOIN ledger_accounts a ON a.user_id = w.user_id
+		 LEFT JOIN postings p ON p.account_id = a.id
+		 WHERE w.user_id = $1
+		 GROUP BY w.balance`,
+		userID,
+	).Scan(&result.Balance, &result.LedgerBalance)
+	if err != nil {
+		return result, err
+	}
+
+	result.Balanced = result.Balance == result.LedgerBalance
+	return result, nil
+}
+
+// Reconcile returns every wallet whose stored balance disagrees with its postings
+func Reconcile(db *sql.DB) ([]models.WalletReconciliation, error) {
+	rows, err := db.Query(
+		`SELECT w.user_id, w.balance, COALESCE(SUM(CASE WHEN p.direction = 'credit' THEN p.amount ELSE -p.amount END), 0) AS ledger_balance
+		 FROM wallets w
+		 LEFT JOIN ledger_accounts a ON a.user_id = w.user_id
+		 LEFT JOIN postings p ON p.account_id = a.id
+		 GROUP BY w.user_id, w.balance
+		 HAVING w.balance <> COALESCE(SUM(CASE WHEN p.direction = 'credit' THEN p.amount ELSE -p.amount END), 0)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []models.WalletReconciliation
+	for rows.Next() {
+		var r models.WalletReconciliation
+		if err := rows.Scan(&r.UserID, &r.Balance, &r.LedgerBalance); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, r)
+	}
+	return mismatches, rows.Err()
+}
+
+// BackfillOpeningBalances gives wallets that predate the ledger an account and an opening entry
+// so that their ledger balance matches the stored balance.
+func BackfillOpeningBalances(db *sql.DB) error {
+	rows, err := db.Query(
+		`SELECT w.user_id, w.balance
+		 FROM wallets w
+		 LEFT JOIN ledger_accounts a ON a.user_id = w.user_id
+		 WHERE a.id IS NULL`,
+	)
+	if err != nil {
+		return err
+	}
+
+	type openingBalance struct {
+		userID  int
+		balance models.Money
+	}
+	var pending []openingBalance
+	for rows.Next() {
+		var ob openingBalance
+		if err := rows.Scan(&ob.userID, &ob.balance); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, ob)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	openingAccountID, err := SystemAccount(tx, OpeningBalance)
+	if err != nil {
+		return err
+	}
+
+	for _, ob := range pending {
+		walletAccountID, err := WalletAccount(tx, ob.userID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case ob.balance > 0:
+			err = Move(tx, 0, "Opening balance", openingAccountID, walletAccountID, ob.balance)
+		case ob.balance < 0:
+			err = Move(tx, 0, "Opening balance", walletAccountID, openingAccountID, -ob.balance)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"log"
+	"transaction-service/clients"
 	"transaction-service/config"
 	"transaction-service/handlers"
+	"transaction-service/ledger"
 	"transaction-service/middleware"
+	"transaction-service/workers"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +20,27 @@ func main() {
 	// Run migrations
 	config.RunMigrations()
 
+	// Make sure every wallet is represented in the ledger and report any drift
+	if err := ledger.BackfillOpeningBalances(config.DB); err != nil {
+		log.Fatal("Failed to backfill ledger opening balances:", err)
+	}
+	mismatches, err := ledger.Reconcile(config.DB)
+	if err != nil {
+		log.Println("Failed to reconcile wallets with the ledger:", err)
+	}
+	for _, m := range mismatches {
+		log.Printf("Ledger mismatch for user_id %d: wallet balance %s, ledger balance %s", m.UserID, m.Balance, m.LedgerBalance)
+	}
+
+	// Resolve transfer receivers and verify access tokens through the auth-service
+	clients.InitUserLookup()
+	clients.InitSigningKeys()
+
+	// Background jobs
+	workers.StartPendingTransferExpiry()
+	workers.StartScheduledTransfers()
+	workers.StartPaymentRequestExpiry()
+
 	router := gin.Default()
 
 	// Health check
@@ -30,13 +54,69 @@ func main() {
 	{
 		// Wallet routes
 		protected.GET("/wallet", handlers.GetWallet)
-		protected.POST("/wallet/add", handlers.AddFunds)
-		protected.POST("/wallet/withdraw", handlers.WithdrawFunds)
+		protected.GET("/wallet/reconcile", handlers.ReconcileWallet)
+		protected.GET("/wallet/limits", handlers.GetWalletLimits)
+		protected.POST("/wallet/add", middleware.Idempotency(), handlers.AddFunds)
+		protected.POST("/wallet/withdraw", middleware.Idempotency(), handlers.WithdrawFunds)
 
 		// Transaction routes
-		protected.POST("/transfer", handlers.Transfer)
+		protected.POST("/transfer", middleware.Idempotency(), handlers.Transfer)
 		protected.GET("/transactions", handlers.GetTransactions)
 		protected.GET("/transactions/:id", handlers.GetTransactionByID)
+		protected.POST("/transactions/:id/accept", handlers.AcceptTransfer)
+		protected.POST("/transactions/:id/decline", handlers.DeclineTransfer)
+		protected.POST("/transactions/:id/refund", middleware.Idempotency(), handlers.RefundTransaction)
+
+		// Batch transfers, many receivers in one call
+		protected.POST("/transfers/batch", middleware.Idempotency(), handlers.BatchTransfer)
+		protected.GET("/transfers/batch/:id", handlers.GetTransferBatch)
+
+		// Bulk payouts from a CSV file, checked in a dry run before they are executed
+		protected.POST("/payouts/dry-run", middleware.RequirePermission("payout:create"), handlers.DryRunPayout)
+		protected.POST("/payouts/:id/execute", middleware.RequirePermission("payout:create"), middleware.Idempotency(), handlers.ExecutePayout)
+		protected.GET("/payouts/:id/result", middleware.RequirePermission("payout:create"), handlers.GetPayoutResult)
+
+		// Scheduled and recurring transfers
+		protected.POST("/scheduled-transfers", middleware.Idempotency(), handlers.CreateScheduledTransfer)
+		protected.GET("/scheduled-transfers", handlers.GetScheduledTransfers)
+		protected.GET("/scheduled-transfers/:id", handlers.GetScheduledTransfer)
+		protected.PUT("/scheduled-transfers/:id", handlers.UpdateScheduledTransfer)
+		protected.POST("/scheduled-transfers/:id/pause", handlers.PauseScheduledTransfer)
+		protected.POST("/scheduled-transfers/:id/resume", handlers.ResumeScheduledTransfer)
+		protected.DELETE("/scheduled-transfers/:id", handlers.CancelScheduledTransfer)
+
+		// Payment requests, asking another user to pay
+		protected.POST("/payment-requests", handlers.CreatePaymentRequest)
+		protected.GET("/payment-requests/incoming", handlers.GetIncomingPaymentRequests)
+		protected.GET("/payment-requests/outgoing", handlers.GetOutgoingPaymentRequests)
+		protected.GET("/payment-requests/:id", handlers.GetPaymentRequest)
+		protected.POST("/payment-requests/:id/pay", middleware.Idempotency(), handlers.PayPaymentRequest)
+		protected.POST("/payment-requests/:id/decline", handlers.DeclinePaymentRequest)
+		protected.POST("/payment-requests/:id/cancel", handlers.CancelPaymentRequest)
+
+		// Bill splits, a payment request per participant
+		protected.POST("/bill-splits", handlers.CreateBillSplit)
+		protected.GET("/bill-splits", handlers.GetBillSplits)
+		protected.GET("/bill-splits/:id", handlers.GetBillSplit)
+		protected.POST("/bill-splits/:id/cancel", handlers.CancelBillSplit)
+	}
+
+	// Admin routes, each guarded by a permission from the caller's access token
+	admin := router.Group("/admin")
+	admin.Use(middleware.AuthMiddleware())
+	{
+		admin.GET("/ledger/reconcile", middleware.RequirePermission("ledger:read"), handlers.ReconcileAllWallets)
+
+		admin.GET("/wallets/:user_id", middleware.RequirePermission("wallet:read"), handlers.AdminGetWallet)
+		admin.GET("/wallets/:user_id/transactions", middleware.RequirePermission("wallet:read"), handlers.AdminGetTransactions)
+		admin.POST("/wallets/:user_id/freeze", middleware.RequirePermission("wallet:freeze"), handlers.FreezeWallet)
+		admin.POST("/wallets/:user_id/unfreeze", middleware.RequirePermission("wallet:freeze"), handlers.UnfreezeWallet)
+		admin.POST("/wallets/:user_id/close", middleware.RequirePermission("wallet:close"), handlers.CloseWallet)
+		admin.POST("/wallets/:user_id/adjustments", middleware.RequirePermission("wallet:adjust"), middleware.Idempotency(), handlers.AdjustWallet)
+		admin.PUT("/wallets/:user_id/tier", middleware.RequirePermission("limits:manage"), handlers.SetWalletTier)
+		admin.GET("/limits", middleware.RequirePermission("wallet:read"), handlers.ListLimitTiers)
+		admin.PUT("/limits/:tier", middleware.RequirePermission("limits:manage"), handlers.SaveLimitTier)
+		admin.GET("/audit-log", middleware.RequirePermission("audit:read"), handlers.GetWalletAuditLog)
 	}
 
 	port := config.GetEnv("PORT", "8082")
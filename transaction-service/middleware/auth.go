@@ -1,10 +1,10 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
-	"transaction-service/config"
+	"transaction-service/clients"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -26,15 +26,17 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		//secret key for jwt using .env files stored jwt key
-		secret := config.GetEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production")
+		//tokens are signed by the auth-service, the public key is looked up in its JWKS by the kid header
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(secret), nil
-		})
+			kid, _ := token.Header["kid"].(string)
+			return clients.SigningKeys.Key(kid, token.Method.Alg())
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}))
 
+		if errors.Is(err, clients.ErrJWKSUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token right now"})
+			c.Abort()
+			return
+		}
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
@@ -56,6 +58,19 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		c.Set("user_id", int(userID))
+		c.Set("permissions", stringClaims(claims["permissions"]))
 		c.Next()
 	}
 }
+
+// stringClaims reads a JSON array claim such as permissions, anything else is treated as empty
+func stringClaims(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
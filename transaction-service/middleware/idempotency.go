@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"transaction-service/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of everything the handler writes so it can be replayed later
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes money moving endpoints safe to retry.
+// Requests carrying an Idempotency-Key header are fingerprinted and the first response is stored,
+// so a replay within the retention window returns the stored response instead of running the handler again.
+// Requests without the header are passed through unchanged.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		// Restore the body so the handler can bind it
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestPath := c.FullPath()
+		requestHash := fingerprintRequest(c.Request.Method, requestPath, body)
+
+		// Forget keys that fell out of the retention window so they can be reused
+		ttlHours, err := strconv.Atoi(config.GetEnv("IDEMPOTENCY_KEY_TTL_HOURS", "24"))
+		if err != nil || ttlHours <= 0 {
+			ttlHours = 24
+		}
+		expiredBefore := time.Now().Add(-time.Duration(ttlHours) * time.Hour)
+		_, err = config.DB.Exec(
+			"DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND created_at < $3",
+			userID, key, expiredBefore,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+			c.Abort()
+			return
+		}
+
+		// Reserve the key; only one request can insert it
+		result, err := config.DB.Exec(
+			`INSERT INTO idempotency_keys (user_id, idempotency_key, request_path, request_hash)
+			 VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (user_id, idempotency_key) DO NOTHING`,
+			userID, key, requestPath, requestHash,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store idempotency key"})
+			c.Abort()
+			return
+		}
+
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			replayIdempotentResponse(c, userID, key, requestPath, requestHash)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler never reaches the status check below, release the key before the panic
+		// goes on to the recovery middleware so the client can retry
+		defer func() {
+			if r := recover(); r != nil {
+				if err := releaseIdempotencyKey(userID, key); err != nil {
+					log.Printf("Failed to release idempotency key %q for user_id %v: %v", key, userID, err)
+				}
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			// Server errors are not final, release the key so the client can retry
+			err = releaseIdempotencyKey(userID, key)
+		} else {
+			_, err = config.DB.Exec(
+				"UPDATE idempotency_keys SET response_status = $1, response_body = $2 WHERE user_id = $3 AND idempotency_key = $4",
+				status, recorder.body.String(), userID, key,
+			)
+		}
+		if err != nil {
+			log.Printf("Failed to save idempotency key %q for user_id %v: %v", key, userID, err)
+		}
+	}
+}
+
+// releaseIdempotencyKey forgets a reserved key whose request did not finish with a final response
+func releaseIdempotencyKey(userID interface{}, key string) error {
+	_, err := config.DB.Exec(
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2",
+		userID, key,
+	)
+	return err
+}
+
+// replayIdempotentResponse answers a request whose key was already used
+func replayIdempotentResponse(c *gin.Context, userID interface{}, key, requestPath, requestHash string) {
+	var storedPath, storedHash string
+	var responseStatus sql.NullInt64
+	var responseBody sql.NullString
+	err := config.DB.QueryRow(
+		`SELECT request_path, request_hash, response_status, response_body
+		 FROM idempotency_keys
+		 WHERE user_id = $1 AND idempotency_key = $2`,
+		userID, key,
+	).Scan(&storedPath, &storedHash, &responseStatus, &responseBody)
+
+	if err == sql.ErrNoRows {
+		// The original request failed and released the key in the meantime
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key was just released, please retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+		return
+	}
+
+	if storedPath != requestPath || storedHash != requestHash {
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+
+	if !responseStatus.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(int(responseStatus.Int64), "application/json; charset=utf-8", []byte(responseBody.String))
+}
+
+func fingerprintRequest(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
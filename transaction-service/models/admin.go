@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AdminActionRequest carries the reason every admin action has to give for the audit log
+type AdminActionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// AdjustmentRequest corrects a wallet balance by hand, credit adds money and debit takes it away
+type AdjustmentRequest struct {
+	Direction string `json:"direction" binding:"required,oneof=credit debit"`
+	Amount    Money  `json:"amount" binding:"required,gt=0"`
+	Reason    string `json:"reason" binding:"required"`
+}
+
+// WalletDetail is a wallet as support staff see it, together with its ledger check
+type WalletDetail struct {
+	Wallet
+	LedgerBalance Money `json:"ledger_balance"`
+	Balanced      bool  `json:"balanced"`
+}
+
+// AuditLogEntry struct for database table wallet_audit_log, one admin action on a user's wallet or on a limit tier.
+// UserID is 0 for actions that concern no single user.
+type AuditLogEntry struct {
+	ID            int             `json:"id"`
+	AdminUserID   int             `json:"admin_user_id"`
+	Action        string          `json:"action"`
+	UserID        int             `json:"user_id,omitempty"`
+	TransactionID *int            `json:"transaction_id,omitempty"`
+	Reason        string          `json:"reason"`
+	Details       json.RawMessage `json:"details"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
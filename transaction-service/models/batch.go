@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// TransferBatch struct for database table transfer_batches together with its items.
+// TotalAmount is what the completed items transferred.
+type TransferBatch struct {
+	ID             int                 `json:"id"`
+	SenderID       int                 `json:"sender_id"`
+	Mode           string              `json:"mode"`
+	Status         string              `json:"status"`
+	TotalAmount    Money               `json:"total_amount"`
+	ItemCount      int                 `json:"item_count"`
+	SucceededCount int                 `json:"succeeded_count"`
+	FailedCount    int                 `json:"failed_count"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Items          []TransferBatchItem `json:"items"`
+}
+
+// TransferBatchItem struct for database table transfer_batch_items, the outcome of one transfer of a batch.
+// Receiver is the receiver as it was given, ReceiverID is set once it was found.
+type TransferBatchItem struct {
+	Position      int    `json:"position"`
+	Receiver      string `json:"receiver"`
+	ReceiverID    *int   `json:"receiver_id,omitempty"`
+	Amount        Money  `json:"amount"`
+	Description   string `json:"description"`
+	Status        string `json:"status"`
+	TransactionID *int   `json:"transaction_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// BatchTransferRequest pays several receivers at once. Mode atomic (the default) makes every transfer or none,
+// best_effort makes those it can and reports the others.
+type BatchTransferRequest struct {
+	Mode  string              `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Items []BatchTransferItem `json:"items" binding:"required,min=1,max=500,dive"`
+}
+
+// BatchTransferItem is one transfer of a batch, the receiver is given like in TransferRequest
+type BatchTransferItem struct {
+	ReceiverID     int    `json:"receiver_id"`
+	ReceiverEmail  string `json:"receiver_email" binding:"omitempty,email"`
+	ReceiverHandle string `json:"receiver_handle"`
+	Amount         Money  `json:"amount" binding:"required,gt=0"`
+	Description    string `json:"description"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Posting directions, a balanced journal entry has equal debit and credit totals
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// LedgerAccount struct for database table ledger_accounts
+type LedgerAccount struct {
+	ID          int       `json:"id"`
+	Code        string    `json:"code"`
+	AccountType string    `json:"account_type"`
+	UserID      *int      `json:"user_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Posting struct for database table postings, one debit or credit line of a journal entry
+type Posting struct {
+	ID             int       `json:"id"`
+	JournalEntryID int       `json:"journal_entry_id"`
+	AccountID      int       `json:"account_id"`
+	Direction      string    `json:"direction"`
+	Amount         Money     `json:"amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// WalletReconciliation compares the stored wallet balance with the balance derived from postings
+type WalletReconciliation struct {
+	UserID        int   `json:"user_id"`
+	Balance       Money `json:"balance"`
+	LedgerBalance Money `json:"ledger_balance"`
+	Balanced      bool  `json:"balanced"`
+}
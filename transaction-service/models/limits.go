@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// LimitTier struct for database table limit_tiers, a nil limit means unlimited
+type LimitTier struct {
+	Tier              string    `json:"tier"`
+	MaxPerTransaction *Money    `json:"max_per_transaction"`
+	DailyAmount       *Money    `json:"daily_amount"`
+	DailyCount        *int      `json:"daily_count"`
+	MonthlyAmount     *Money    `json:"monthly_amount"`
+	MonthlyCount      *int      `json:"monthly_count"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// UpdateLimitTierRequest replaces every limit of a tier, leaving a limit out removes it
+type UpdateLimitTierRequest struct {
+	MaxPerTransaction *Money `json:"max_per_transaction" binding:"omitempty,gt=0"`
+	DailyAmount       *Money `json:"daily_amount" binding:"omitempty,gt=0"`
+	DailyCount        *int   `json:"daily_count" binding:"omitempty,gt=0"`
+	MonthlyAmount     *Money `json:"monthly_amount" binding:"omitempty,gt=0"`
+	MonthlyCount      *int   `json:"monthly_count" binding:"omitempty,gt=0"`
+	Reason            string `json:"reason" binding:"required"`
+}
+
+// SetTierRequest moves a wallet to another limit tier
+type SetTierRequest struct {
+	Tier   string `json:"tier" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// LimitUsage is what a user has sent in the current day and month against the limits of their tier
+type LimitUsage struct {
+	Limits          LimitTier `json:"limits"`
+	DailyAmount     Money     `json:"daily_amount"`
+	DailyCount      int       `json:"daily_count"`
+	DailyResetsAt   time.Time `json:"daily_resets_at"`
+	MonthlyAmount   Money     `json:"monthly_amount"`
+	MonthlyCount    int       `json:"monthly_count"`
+	MonthlyResetsAt time.Time `json:"monthly_resets_at"`
+}
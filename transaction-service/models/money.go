@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrencyFractionDigits is the number of decimal places the wallet currency allows
+const CurrencyFractionDigits = 2
+
+const (
+	// minorUnitsPerMajor is 10^CurrencyFractionDigits
+	minorUnitsPerMajor = Money(100)
+	// maxMoney is the largest amount that fits the DECIMAL(15, 2) columns
+	maxMoney = Money(999999999999999)
+)
+
+var (
+	ErrInvalidAmount         = errors.New("amount must be a decimal number")
+	ErrTooManyFractionDigits = fmt.Errorf("amount must have at most %d decimal places", CurrencyFractionDigits)
+	ErrAmountOutOfRange      = errors.New("amount is too large")
+)
+
+// Money is an exact amount stored in minor units (cents) so that wallet math never rounds.
+// It is encoded as a JSON number with two decimals and stored as a DECIMAL(15, 2) in the database.
+type Money int64
+
+// ParseMoney converts a decimal string such as "10.25" into Money without going through float64
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, ErrInvalidAmount
+	}
+	if len(fraction) > CurrencyFractionDigits {
+		return 0, ErrTooManyFractionDigits
+	}
+
+	// Pad the fraction so "10.5" becomes 1050 cents
+	fraction += strings.Repeat("0", CurrencyFractionDigits-len(fraction))
+	whole = strings.TrimLeft(whole, "0")
+	if len(whole) > 13 {
+		return 0, ErrAmountOutOfRange
+	}
+
+	minorUnits, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, ErrAmountOutOfRange
+	}
+	if negative {
+		minorUnits = -minorUnits
+	}
+	return Money(minorUnits), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with exactly two decimal places
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/minorUnitsPerMajor, m%minorUnitsPerMajor)
+}
+
+// MarshalJSON writes the amount as a plain JSON number, e.g. 10.50
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string and rejects extra decimal places
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads DECIMAL columns, which lib/pq returns as text
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = Money(v) * minorUnitsPerMajor
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+// Value passes the amount to the database as exact decimal text
+func (m Money) Value() (driver.Value, error) {
+	if m > maxMoney || m < -maxMoney {
+		return nil, ErrAmountOutOfRange
+	}
+	return m.String(), nil
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{"10.25", 1025, nil},
+		{"10.5", 1050, nil},
+		{"10", 1000, nil},
+		{"10.", 1000, nil},
+		{"0.01", 1, nil},
+		{"0001.10", 110, nil},
+		{" 7.00 ", 700, nil},
+		{"+5", 500, nil},
+		{"-0.01", -1, nil},
+		{"-12.34", -1234, nil},
+		{"9999999999999.99", maxMoney, nil},
+		{"-9999999999999.99", -maxMoney, nil},
+		{"10.255", 0, ErrTooManyFractionDigits},
+		{"0.001", 0, ErrTooManyFractionDigits},
+		{"-1.000", 0, ErrTooManyFractionDigits},
+		{"10000000000000", 0, ErrAmountOutOfRange},
+		{"-10000000000000.00", 0, ErrAmountOutOfRange},
+		{"99999999999999999999", 0, ErrAmountOutOfRange},
+		{"", 0, ErrInvalidAmount},
+		{"-", 0, ErrInvalidAmount},
+		{".5", 0, ErrInvalidAmount},
+		{"--5", 0, ErrInvalidAmount},
+		{"+-5", 0, ErrInvalidAmount},
+		{"1e3", 0, ErrInvalidAmount},
+		{"1,000.00", 0, ErrInvalidAmount},
+		{"10.2.5", 0, ErrInvalidAmount},
+		{"ten", 0, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{`10.25`, 1025, nil},
+		{`"10.25"`, 1025, nil},
+		{`-3.5`, -350, nil},
+		{`null`, 42, nil},
+		{`10.255`, 42, ErrTooManyFractionDigits},
+		{`"0.001"`, 42, ErrTooManyFractionDigits},
+		{`10000000000000`, 42, ErrAmountOutOfRange},
+		{`1e2`, 42, ErrInvalidAmount},
+		{`true`, 42, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			// Start from a known value to check that failures and null leave it alone
+			m := Money(42)
+			err := m.UnmarshalJSON([]byte(tt.in))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnmarshalJSON(%s) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			if m != tt.want {
+				t.Fatalf("UnmarshalJSON(%s) = %d, want %d", tt.in, m, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{"decimal bytes", []byte("12.34"), 1234, false},
+		{"decimal string", "12.34", 1234, false},
+		{"negative decimal", []byte("-0.50"), -50, false},
+		{"largest column value", []byte("9999999999999.99"), maxMoney, false},
+		{"integer", int64(5), 500, false},
+		{"null", nil, 0, false},
+		{"excess fraction digits", []byte("12.345"), 0, true},
+		{"out of range", "10000000000000.00", 0, true},
+		{"not a number", []byte("abc"), 0, true},
+		{"float", 12.34, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			err := m.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%v) error = %v, wantErr %v", tt.src, err, tt.wantErr)
+			}
+			if m != tt.want {
+				t.Fatalf("Scan(%v) = %d, want %d", tt.src, m, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{1050, "10.50"},
+		{-1, "-0.01"},
+		{-1234, "-12.34"},
+		{maxMoney, "9999999999999.99"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.m.String(); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyValueRejectsOutOfRange(t *testing.T) {
+	for _, m := range []Money{maxMoney + 1, -maxMoney - 1} {
+		if _, err := m.Value(); !errors.Is(err, ErrAmountOutOfRange) {
+			t.Fatalf("Value() of %d error = %v, want ErrAmountOutOfRange", m, err)
+		}
+	}
+}
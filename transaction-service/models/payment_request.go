@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// PaymentRequest struct for database table payment_requests.
+// TransactionID is set once the payer has paid the request.
+type PaymentRequest struct {
+	ID            int       `json:"id"`
+	RequesterID   int       `json:"requester_id"`
+	PayerID       int       `json:"payer_id"`
+	Amount        Money     `json:"amount"`
+	Description   string    `json:"description"`
+	Status        string    `json:"status"`
+	TransactionID *int      `json:"transaction_id,omitempty"`
+	SplitID       *int      `json:"split_id,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PaymentRequestRequest asks another user to pay. The payer is given by exactly one of
+// payer_id, payer_email or payer_handle, expires_in_hours defaults to PAYMENT_REQUEST_TTL_HOURS.
+type PaymentRequestRequest struct {
+	PayerID        int    `json:"payer_id"`
+	PayerEmail     string `json:"payer_email" binding:"omitempty,email"`
+	PayerHandle    string `json:"payer_handle"`
+	Amount         Money  `json:"amount" binding:"required,gt=0"`
+	Description    string `json:"description"`
+	ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
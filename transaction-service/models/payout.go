@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Payout struct for database table payout_files, a bulk payout uploaded as CSV.
+// Rows are only filled in by the dry run, BatchID once the file has been executed.
+type Payout struct {
+	ID              int                 `json:"id"`
+	SenderID        int                 `json:"sender_id"`
+	FileName        string              `json:"file_name"`
+	Status          string              `json:"status"`
+	RowCount        int                 `json:"row_count"`
+	ValidCount      int                 `json:"valid_count"`
+	ErrorCount      int                 `json:"error_count"`
+	TotalAmount     Money               `json:"total_amount"`
+	SufficientFunds *bool               `json:"sufficient_funds,omitempty"`
+	BatchID         *int                `json:"batch_id,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	ExecutedAt      *time.Time          `json:"executed_at,omitempty"`
+	Rows            []TransferBatchItem `json:"rows,omitempty"`
+}
+
+// ExecutePayoutRequest executes a dry-run payout file, mode works like in BatchTransferRequest
+type ExecutePayoutRequest struct {
+	Mode string `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+}
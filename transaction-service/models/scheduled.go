@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// ScheduledTransfer struct for database table scheduled_transfers.
+// NextRunAt is nil once the schedule has no further run.
+type ScheduledTransfer struct {
+	ID                  int                    `json:"id"`
+	SenderID            int                    `json:"sender_id"`
+	ReceiverID          int                    `json:"receiver_id"`
+	Amount              Money                  `json:"amount"`
+	Description         string                 `json:"description"`
+	Frequency           string                 `json:"frequency"`
+	CronExpression      string                 `json:"cron_expression,omitempty"`
+	StartAt             time.Time              `json:"start_at"`
+	EndAt               *time.Time             `json:"end_at,omitempty"`
+	NextRunAt           *time.Time             `json:"next_run_at"`
+	Status              string                 `json:"status"`
+	OnInsufficientFunds string                 `json:"on_insufficient_funds"`
+	MaxRetries          int                    `json:"max_retries"`
+	RetryCount          int                    `json:"retry_count"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+	Runs                []ScheduledTransferRun `json:"runs,omitempty"`
+}
+
+// ScheduledTransferRun struct for database table scheduled_transfer_runs
+type ScheduledTransferRun struct {
+	ID                  int       `json:"id"`
+	ScheduledTransferID int       `json:"scheduled_transfer_id"`
+	TransactionID       *int      `json:"transaction_id,omitempty"`
+	Status              string    `json:"status"`
+	Error               string    `json:"error,omitempty"`
+	ScheduledFor        time.Time `json:"scheduled_for"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// ScheduledTransferRequest creates a scheduled transfer. The receiver is given like in TransferRequest,
+// start_at defaults to now and on_insufficient_funds to skip.
+type ScheduledTransferRequest struct {
+	ReceiverID          int        `json:"receiver_id"`
+	ReceiverEmail       string     `json:"receiver_email"`
+	ReceiverHandle      string     `json:"receiver_handle"`
+	Amount              Money      `json:"amount" binding:"required,gt=0"`
+	Description         string     `json:"description"`
+	Frequency           string     `json:"frequency" binding:"required,oneof=once daily weekly monthly cron"`
+	CronExpression      string     `json:"cron_expression"`
+	StartAt             *time.Time `json:"start_at"`
+	EndAt               *time.Time `json:"end_at"`
+	OnInsufficientFunds string     `json:"on_insufficient_funds" binding:"omitempty,oneof=skip retry"`
+	MaxRetries          *int       `json:"max_retries" binding:"omitempty,min=0,max=10"`
+}
+
+// UpdateScheduledTransferRequest changes an active or paused scheduled transfer, fields left out keep their value
+type UpdateScheduledTransferRequest struct {
+	Amount              *Money     `json:"amount" binding:"omitempty,gt=0"`
+	Description         *string    `json:"description"`
+	Frequency           *string    `json:"frequency" binding:"omitempty,oneof=once daily weekly monthly cron"`
+	CronExpression      *string    `json:"cron_expression"`
+	StartAt             *time.Time `json:"start_at"`
+	EndAt               *time.Time `json:"end_at"`
+	OnInsufficientFunds *string    `json:"on_insufficient_funds" binding:"omitempty,oneof=skip retry"`
+	MaxRetries          *int       `json:"max_retries" binding:"omitempty,min=0,max=10"`
+}
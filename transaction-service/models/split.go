@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// BillSplit struct for database table bill_splits together with its shares.
+// CreatorShare is the part the creator pays themselves, SettledAmount includes it.
+type BillSplit struct {
+	ID            int              `json:"id"`
+	CreatorID     int              `json:"creator_id"`
+	TotalAmount   Money            `json:"total_amount"`
+	Description   string           `json:"description"`
+	SplitType     string           `json:"split_type"`
+	CreatorShare  Money            `json:"creator_share"`
+	Status        string           `json:"status"`
+	SettledAmount Money            `json:"settled_amount"`
+	Shares        []BillSplitShare `json:"shares"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// BillSplitShare is what one participant owes, settled once their payment request is paid.
+// The creator's own share has no payment request and is always settled.
+type BillSplitShare struct {
+	UserID           int    `json:"user_id"`
+	Amount           Money  `json:"amount"`
+	Status           string `json:"status"`
+	PaymentRequestID *int   `json:"payment_request_id,omitempty"`
+	TransactionID    *int   `json:"transaction_id,omitempty"`
+}
+
+// BillSplitRequest splits total_amount between the participants. With split_type equal every participant
+// owes the same, exact takes an amount per participant and percentage a percentage with up to two decimals.
+// The creator may list themselves to pay a share too.
+type BillSplitRequest struct {
+	TotalAmount    Money                  `json:"total_amount" binding:"required,gt=0"`
+	Description    string                 `json:"description"`
+	SplitType      string                 `json:"split_type" binding:"required,oneof=equal exact percentage"`
+	Participants   []BillSplitParticipant `json:"participants" binding:"required,min=1,max=50,dive"`
+	ExpiresInHours int                    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// BillSplitParticipant is given by exactly one of user_id, email or handle
+type BillSplitParticipant struct {
+	UserID     int      `json:"user_id"`
+	Email      string   `json:"email" binding:"omitempty,email"`
+	Handle     string   `json:"handle"`
+	Amount     *Money   `json:"amount" binding:"omitempty,gt=0"`
+	Percentage *float64 `json:"percentage" binding:"omitempty,gt=0,lte=100"`
+}
@@ -3,23 +3,73 @@ package models
 import "time"
 
 // database table transactions
+// OriginalTransactionID links a refund to the transfer it gives money back for,
+// BatchID a transfer to the batch that made it
 type Transaction struct {
-	ID              int       `json:"id"`
-	SenderID        int       `json:"sender_id"`
-	ReceiverID      int       `json:"receiver_id"`
-	Amount          float64   `json:"amount"`
-	Status          string    `json:"status"`
-	Description     string    `json:"description"`
-	TransactionType string    `json:"transaction_type"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
-}
-
-// Defines the request body for transfer
+	ID                    int        `json:"id"`
+	SenderID              int        `json:"sender_id"`
+	ReceiverID            int        `json:"receiver_id"`
+	Amount                Money      `json:"amount"`
+	Status                string     `json:"status"`
+	Description           string     `json:"description"`
+	TransactionType       string     `json:"transaction_type"`
+	ExpiresAt             *time.Time `json:"expires_at,omitempty"`
+	OriginalTransactionID *int       `json:"original_transaction_id,omitempty"`
+	BatchID               *int       `json:"batch_id,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+	// Counterparty is only filled in the transaction history
+	Counterparty *Counterparty `json:"counterparty,omitempty"`
+}
+
+// Counterparty is the other user of a transaction, without the email so the history does not reveal it
+type Counterparty struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Handle string `json:"handle,omitempty"`
+}
+
+// TransactionFilter holds the optional filters of GET /transactions, zero values mean no filter
+type TransactionFilter struct {
+	TransactionType string
+	Status          string
+	CounterpartyID  int
+	MinAmount       *Money
+	MaxAmount       *Money
+	From            *time.Time
+	To              *time.Time
+	Cursor          string
+	Limit           int
+}
+
+// TransactionPage is one page of transactions, NextCursor is null on the last page
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   *string       `json:"next_cursor"`
+}
+
+// Defines the request body for transfer, the receiver is given by exactly one of id, email or handle
 type TransferRequest struct {
-	ReceiverID  int     `json:"receiver_id" binding:"required"`
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	Description string  `json:"description"`
+	ReceiverID     int    `json:"receiver_id"`
+	ReceiverEmail  string `json:"receiver_email" binding:"omitempty,email"`
+	ReceiverHandle string `json:"receiver_handle"`
+	Amount         Money  `json:"amount" binding:"required,gt=0"`
+	Description    string `json:"description"`
+	// RequireAcceptance holds the funds until the receiver accepts, declined or expired transfers go back to the sender
+	RequireAcceptance bool `json:"require_acceptance"`
+}
+
+// RefundRequest refunds a received transfer, the whole remaining amount when Amount is left out
+type RefundRequest struct {
+	Amount *Money `json:"amount" binding:"omitempty,gt=0"`
+	Reason string `json:"reason"`
+}
+
+// TransactionDetail is a transaction together with the refunds made against it
+type TransactionDetail struct {
+	Transaction
+	RefundedAmount Money         `json:"refunded_amount"`
+	Refunds        []Transaction `json:"refunds"`
 }
 
 // Defines the response body for transfer
@@ -0,0 +1,11 @@
+package models
+
+// User is the part of an auth-service user the transaction-service needs
+type User struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Handle     string `json:"handle,omitempty"`
+	IsVerified bool   `json:"is_verified"`
+	Status     string `json:"status,omitempty"`
+}
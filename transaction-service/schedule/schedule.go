@@ -0,0 +1,256 @@
+// Package schedule computes the run times of scheduled transfers.
+// All times are handled in UTC.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of a scheduled transfer
+const (
+	Once    = "once"
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+	Cron    = "cron"
+)
+
+// Cron expressions are searched at most this far ahead, an expression such as "0 0 30 2 *" never matches
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+var ErrNoOccurrence = errors.New("schedule has no further occurrence")
+
+// Spec describes when a scheduled transfer runs. Daily, weekly and monthly schedules repeat
+// at the time of day and day of month of StartAt, cron schedules run at every match from StartAt on.
+type Spec struct {
+	Frequency string
+	Cron      string
+	StartAt   time.Time
+}
+
+// Validate checks the frequency and, for cron schedules, the expression
+func (s Spec) Validate() error {
+	switch s.Frequency {
+	case Once, Daily, Weekly, Monthly:
+		if s.Cron != "" {
+			return errors.New("cron_expression is only allowed with the cron frequency")
+		}
+		return nil
+	case Cron:
+		_, err := parseCron(s.Cron)
+		return err
+	default:
+		return fmt.Errorf("unknown frequency %q", s.Frequency)
+	}
+}
+
+// Next returns the first occurrence at or after from, or ErrNoOccurrence when there is none
+func (s Spec) Next(from time.Time) (time.Time, error) {
+	start := s.StartAt.UTC()
+	from = from.UTC()
+
+	switch s.Frequency {
+	case Once:
+		if from.After(start) {
+			return time.Time{}, ErrNoOccurrence
+		}
+		return start, nil
+
+	case Daily, Weekly:
+		if !from.After(start) {
+			return start, nil
+		}
+		days := 1
+		if s.Frequency == Weekly {
+			days = 7
+		}
+		// Whole periods since the start, rounded up
+		period := time.Duration(days) * 24 * time.Hour
+		n := int((from.Sub(start) + period - 1) / period)
+		return start.AddDate(0, 0, n*days), nil
+
+	case Monthly:
+		if !from.After(start) {
+			return start, nil
+		}
+		n := (from.Year()-start.Year())*12 + int(from.Month()-start.Month())
+		if n < 0 {
+			n = 0
+		}
+		for {
+			occurrence := addMonthsClamped(start, n)
+			if !occurrence.Before(from) {
+				return occurrence, nil
+			}
+			n++
+		}
+
+	case Cron:
+		expr, err := parseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if from.Before(start) {
+			from = start
+		}
+		return expr.next(from)
+
+	default:
+		return time.Time{}, fmt.Errorf("unknown frequency %q", s.Frequency)
+	}
+}
+
+// addMonthsClamped adds months to t, a day that does not exist in the target month becomes its last day,
+// so a transfer started on January 31st runs on February 28th and March 31st
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// cronExpr is a parsed five field cron expression: minute, hour, day of month, month and day of week
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// Like classic cron, a day matches either field when both day fields are restricted
+	domRestricted, dowRestricted bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses expressions like "30 9 1 * *" or "0 8 * * 1-5" with lists, ranges and steps.
+// Day of week 0 and 7 are both Sunday.
+func parseCron(expression string) (cronExpr, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return cronExpr{}, errors.New("cron_expression must have five fields: minute hour day-of-month month day-of-week")
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return cronExpr{}, err
+		}
+		bits[i] = b
+	}
+
+	// Fold Sunday as 7 onto 0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return cronExpr{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, spec.name)
+			}
+			step = s
+		}
+
+		low, high := spec.min, spec.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			lowPart, highPart, _ := strings.Cut(rangePart, "-")
+			l, err1 := strconv.Atoi(lowPart)
+			h, err2 := strconv.Atoi(highPart)
+			if err1 != nil || err2 != nil || l > h {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, spec.name)
+			}
+			low, high = l, h
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", rangePart, spec.name)
+			}
+			low = v
+			// "5/15" means every 15 from 5 on, a plain "5" is just 5
+			if !hasStep {
+				high = v
+			}
+		}
+
+		if low < spec.min || high > spec.max {
+			return 0, fmt.Errorf("%s field must be between %d and %d", spec.name, spec.min, spec.max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (e cronExpr) dayMatches(t time.Time) bool {
+	domMatch := e.dom&(1<<uint(t.Day())) != 0
+	dowMatch := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domRestricted && e.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// next returns the first minute at or after from that matches the expression
+func (e cronExpr) next(from time.Time) (time.Time, error) {
+	t := from.Truncate(time.Minute)
+	if t.Before(from) {
+		t = t.Add(time.Minute)
+	}
+	limit := t.Add(maxCronSearch)
+
+	for t.Before(limit) {
+		if e.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !e.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if e.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if e.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, ErrNoOccurrence
+}
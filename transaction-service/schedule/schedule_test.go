@@ -0,0 +1,118 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	// January 1st 2026 is a Thursday
+	start := at(2026, time.January, 1, 9, 0)
+
+	tests := []struct {
+		name string
+		spec Spec
+		from time.Time
+		want time.Time
+	}{
+		{"once before the start", Spec{Frequency: Once, StartAt: start}, at(2025, time.December, 1, 0, 0), start},
+		{"once at the start", Spec{Frequency: Once, StartAt: start}, start, start},
+
+		{"daily before the start", Spec{Frequency: Daily, StartAt: start}, at(2025, time.December, 1, 0, 0), start},
+		{"daily at an occurrence", Spec{Frequency: Daily, StartAt: start}, at(2026, time.January, 5, 9, 0), at(2026, time.January, 5, 9, 0)},
+		{"daily after the time of day", Spec{Frequency: Daily, StartAt: start}, at(2026, time.January, 5, 10, 0), at(2026, time.January, 6, 9, 0)},
+		{"weekly", Spec{Frequency: Weekly, StartAt: start}, at(2026, time.January, 2, 0, 0), at(2026, time.January, 8, 9, 0)},
+
+		{"monthly clamps to February 28th", Spec{Frequency: Monthly, StartAt: at(2026, time.January, 31, 9, 0)}, at(2026, time.February, 1, 0, 0), at(2026, time.February, 28, 9, 0)},
+		{"monthly goes back to the 31st", Spec{Frequency: Monthly, StartAt: at(2026, time.January, 31, 9, 0)}, at(2026, time.March, 1, 0, 0), at(2026, time.March, 31, 9, 0)},
+		{"monthly clamps to April 30th", Spec{Frequency: Monthly, StartAt: at(2026, time.January, 31, 9, 0)}, at(2026, time.April, 1, 0, 0), at(2026, time.April, 30, 9, 0)},
+		{"monthly clamps to February 29th in a leap year", Spec{Frequency: Monthly, StartAt: at(2028, time.January, 31, 9, 0)}, at(2028, time.February, 1, 0, 0), at(2028, time.February, 29, 9, 0)},
+		{"monthly later the same day", Spec{Frequency: Monthly, StartAt: at(2026, time.January, 15, 9, 0)}, at(2026, time.March, 15, 10, 0), at(2026, time.April, 15, 9, 0)},
+
+		{"cron rounds up to the next minute", Spec{Frequency: Cron, Cron: "* * * * *", StartAt: start}, start.Add(90 * time.Second), at(2026, time.January, 1, 9, 2)},
+		{"cron starts at the start", Spec{Frequency: Cron, Cron: "0 0 * * *", StartAt: at(2026, time.March, 1, 0, 0)}, start, at(2026, time.March, 1, 0, 0)},
+		{"cron step from any", Spec{Frequency: Cron, Cron: "*/15 * * * *", StartAt: start}, at(2026, time.January, 1, 10, 7), at(2026, time.January, 1, 10, 15)},
+		{"cron step from a value", Spec{Frequency: Cron, Cron: "5/20 * * * *", StartAt: start}, at(2026, time.January, 1, 10, 26), at(2026, time.January, 1, 10, 45)},
+		{"cron step over a range", Spec{Frequency: Cron, Cron: "0 9-17/4 * * *", StartAt: start}, at(2026, time.January, 1, 10, 0), at(2026, time.January, 1, 13, 0)},
+		{"cron list", Spec{Frequency: Cron, Cron: "0 8,20 * * *", StartAt: start}, at(2026, time.January, 1, 10, 0), at(2026, time.January, 1, 20, 0)},
+		{"cron weekdays skip the weekend", Spec{Frequency: Cron, Cron: "0 8 * * 1-5", StartAt: start}, at(2026, time.January, 3, 0, 0), at(2026, time.January, 5, 8, 0)},
+		{"cron day of month only", Spec{Frequency: Cron, Cron: "0 0 13 * *", StartAt: start}, at(2026, time.January, 3, 0, 0), at(2026, time.January, 13, 0, 0)},
+		{"cron day of month or weekday, the Friday first", Spec{Frequency: Cron, Cron: "0 0 13 * 5", StartAt: start}, at(2026, time.January, 3, 0, 0), at(2026, time.January, 9, 0, 0)},
+		{"cron day of month or weekday, the 13th first", Spec{Frequency: Cron, Cron: "0 0 13 * 5", StartAt: start}, at(2026, time.January, 10, 0, 0), at(2026, time.January, 13, 0, 0)},
+		{"cron Sunday as 0", Spec{Frequency: Cron, Cron: "0 8 * * 0", StartAt: start}, start, at(2026, time.January, 4, 8, 0)},
+		{"cron Sunday as 7", Spec{Frequency: Cron, Cron: "0 8 * * 7", StartAt: start}, start, at(2026, time.January, 4, 8, 0)},
+		{"cron range ending on Sunday as 7", Spec{Frequency: Cron, Cron: "0 8 * * 6-7", StartAt: start}, at(2026, time.January, 3, 9, 0), at(2026, time.January, 4, 8, 0)},
+		{"cron February 29th", Spec{Frequency: Cron, Cron: "0 0 29 2 *", StartAt: start}, start, at(2028, time.February, 29, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.spec.Next(tt.from)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextNoOccurrence(t *testing.T) {
+	start := at(2026, time.January, 1, 9, 0)
+
+	tests := []struct {
+		name string
+		spec Spec
+		from time.Time
+	}{
+		{"once after the start", Spec{Frequency: Once, StartAt: start}, start.Add(time.Second)},
+		{"cron day that never exists", Spec{Frequency: Cron, Cron: "0 0 30 2 *", StartAt: start}, start},
+		{"cron day beyond the month", Spec{Frequency: Cron, Cron: "0 0 31 4,6,9,11 *", StartAt: start}, start},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.spec.Next(tt.from)
+			if !errors.Is(err, ErrNoOccurrence) {
+				t.Fatalf("error = %v, want ErrNoOccurrence", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr bool
+	}{
+		{"daily", Spec{Frequency: Daily}, false},
+		{"cron", Spec{Frequency: Cron, Cron: "30 9 1 * *"}, false},
+		{"cron with lists, ranges and steps", Spec{Frequency: Cron, Cron: "0,30 9-17/2 1-15 */3 1-5"}, false},
+		{"expression without the cron frequency", Spec{Frequency: Daily, Cron: "0 0 * * *"}, true},
+		{"unknown frequency", Spec{Frequency: "yearly"}, true},
+		{"missing expression", Spec{Frequency: Cron}, true},
+		{"four fields", Spec{Frequency: Cron, Cron: "0 0 * *"}, true},
+		{"minute out of range", Spec{Frequency: Cron, Cron: "60 * * * *"}, true},
+		{"day of month zero", Spec{Frequency: Cron, Cron: "0 0 0 * *"}, true},
+		{"day of week out of range", Spec{Frequency: Cron, Cron: "0 0 * * 8"}, true},
+		{"reversed range", Spec{Frequency: Cron, Cron: "0 17-9 * * *"}, true},
+		{"zero step", Spec{Frequency: Cron, Cron: "*/0 * * * *"}, true},
+		{"not a number", Spec{Frequency: Cron, Cron: "0 0 * JAN *"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
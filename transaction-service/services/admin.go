@@ -0,0 +1,181 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"transaction-service/ledger"
+	"transaction-service/models"
+)
+
+// Actions recorded in wallet_audit_log
+const (
+	AuditWalletFrozen      = "wallet_frozen"
+	AuditWalletUnfrozen    = "wallet_unfrozen"
+	AuditWalletClosed      = "wallet_closed"
+	AuditWalletAdjusted    = "wallet_adjusted"
+	AuditWalletTierChanged = "wallet_tier_changed"
+	AuditLimitTierSaved    = "limit_tier_saved"
+)
+
+var (
+	ErrWalletStatusUnchanged = errors.New("wallet already has this status")
+	ErrWalletNotEmpty        = errors.New("wallet still holds money")
+	ErrWalletHasPending      = errors.New("wallet has pending transfers")
+)
+
+// SetWalletStatus freezes, unfreezes or closes a wallet on behalf of an admin and records why.
+// Closing is final and only allowed once the wallet is empty and no pending transfer can still land in it.
+func SetWalletStatus(tx *sql.Tx, adminID, userID int, status, action, reason string) (models.Wallet, error) {
+	var previous string
+	var balance models.Money
+	err := tx.QueryRow("SELECT status, balance FROM wallets WHERE user_id = $1 FOR UPDATE", userID).Scan(&previous, &balance)
+	if err == sql.ErrNoRows {
+		return models.Wallet{}, ErrWalletNotFound
+	}
+	if err != nil {
+		return models.Wallet{}, err
+	}
+	if previous == status {
+		return models.Wallet{}, ErrWalletStatusUnchanged
+	}
+	if previous == WalletClosed {
+		return models.Wallet{}, ErrWalletClosed
+	}
+
+	if status == WalletClosed {
+		if balance != 0 {
+			return models.Wallet{}, ErrWalletNotEmpty
+		}
+		var hasPending bool
+		err = tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM transactions WHERE status = 'pending' AND (sender_id = $1 OR receiver_id = $1))",
+			userID,
+		).Scan(&hasPending)
+		if err != nil {
+			return models.Wallet{}, err
+		}
+		if hasPending {
+			return models.Wallet{}, ErrWalletHasPending
+		}
+	}
+
+	var wallet models.Wallet
+	err = ScanWallet(tx.QueryRow(
+		`UPDATE wallets SET status = $1, updated_at = NOW() WHERE user_id = $2 RETURNING `+WalletColumns,
+		status, userID,
+	), &wallet)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	err = RecordAdminAction(tx, models.AuditLogEntry{
+		AdminUserID: adminID,
+		Action:      action,
+		UserID:      userID,
+		Reason:      reason,
+	}, map[string]interface{}{"previous_status": previous, "status": status})
+	return wallet, err
+}
+
+// Adjust corrects a wallet balance by hand. The money comes from or goes to the manual adjustments
+// ledger account, so every correction stays visible in the ledger and in the user's history.
+// A frozen wallet can be corrected both ways, a closed one not at all.
+func Adjust(tx *sql.Tx, adminID, userID int, direction string, amount models.Money, reason string) (models.Transaction, models.Money, error) {
+	var newBalance models.Money
+	var err error
+	if direction == models.Credit {
+		newBalance, err = CreditWallet(tx, userID, amount)
+	} else {
+		newBalance, err = debitWallet(tx, userID, amount, true)
+	}
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+
+	transaction, err := insertTransaction(tx, models.Transaction{
+		SenderID:        userID,
+		ReceiverID:      userID,
+		Amount:          amount,
+		Status:          "completed",
+		TransactionType: "adjustment_" + direction,
+		Description:     reason,
+	})
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+
+	adjustmentsAccountID, err := ledger.SystemAccount(tx, ledger.Adjustments)
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+	walletAccountID, err := ledger.WalletAccount(tx, userID)
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+	if direction == models.Credit {
+		err = ledger.Move(tx, transaction.ID, "Manual adjustment", adjustmentsAccountID, walletAccountID, amount)
+	} else {
+		err = ledger.Move(tx, transaction.ID, "Manual adjustment", walletAccountID, adjustmentsAccountID, amount)
+	}
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+
+	err = RecordAdminAction(tx, models.AuditLogEntry{
+		AdminUserID:   adminID,
+		Action:        AuditWalletAdjusted,
+		UserID:        userID,
+		TransactionID: &transaction.ID,
+		Reason:        reason,
+	}, map[string]interface{}{"direction": direction, "amount": amount, "new_balance": newBalance})
+	if err != nil {
+		return models.Transaction{}, 0, err
+	}
+
+	return transaction, newBalance, nil
+}
+
+// RecordAdminAction writes an entry to the wallet audit log in the same transaction as the action itself
+func RecordAdminAction(tx *sql.Tx, entry models.AuditLogEntry, details map[string]interface{}) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO wallet_audit_log (admin_user_id, action, user_id, transaction_id, reason, details)
+		 VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)`,
+		entry.AdminUserID, entry.Action, entry.UserID, entry.TransactionID, entry.Reason, detailsJSON,
+	)
+	return err
+}
+
+// ListAuditLog returns the newest audit log entries, for one user or for everyone when userID is 0
+func ListAuditLog(db *sql.DB, userID, limit int) ([]models.AuditLogEntry, error) {
+	rows, err := db.Query(
+		`SELECT id, admin_user_id, action, COALESCE(user_id, 0), transaction_id, reason, details, created_at
+		 FROM wallet_audit_log
+		 WHERE $1 = 0 OR user_id = $1
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLogEntry{}
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		var details []byte
+		err := rows.Scan(&entry.ID, &entry.AdminUserID, &entry.Action, &entry.UserID, &entry.TransactionID, &entry.Reason, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
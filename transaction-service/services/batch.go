@@ -0,0 +1,274 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"transaction-service/models"
+)
+
+// Batch modes: atomic makes every transfer or none, best_effort makes those it can
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// Batch statuses, partial when only some of the items were transferred
+const (
+	BatchCompleted = "completed"
+	BatchPartial   = "partial"
+	BatchFailed    = "failed"
+)
+
+// Batch item statuses, not_executed items were rolled back or never tried because another item failed
+const (
+	BatchItemCompleted   = "completed"
+	BatchItemFailed      = "failed"
+	BatchItemNotExecuted = "not_executed"
+)
+
+var ErrBatchNotFound = errors.New("transfer batch not found")
+
+// BatchRejectedError is returned when an atomic batch has an item that cannot be transferred.
+// Nothing was transferred, Items says which items failed and why.
+type BatchRejectedError struct {
+	Items []models.TransferBatchItem
+}
+
+func (e *BatchRejectedError) Error() string {
+	for _, item := range e.Items {
+		if item.Status == BatchItemFailed {
+			return fmt.Sprintf("batch rejected, item %d failed: %s", item.Position, item.Error)
+		}
+	}
+	return "batch rejected"
+}
+
+// TransferBatchColumns lists the transfer_batches columns in the order ScanTransferBatch reads them
+const TransferBatchColumns = "id, sender_id, mode, status, total_amount, item_count, succeeded_count, created_at"
+
+// ScanTransferBatch reads a row selected with TransferBatchColumns
+func ScanTransferBatch(row scanner, batch *models.TransferBatch) error {
+	err := row.Scan(&batch.ID, &batch.SenderID, &batch.Mode, &batch.Status, &batch.TotalAmount, &batch.ItemCount, &batch.SucceededCount, &batch.CreatedAt)
+	batch.FailedCount = batch.ItemCount - batch.SucceededCount
+	return err
+}
+
+// ResolveBatchItems looks up the receiver of every item. An item whose receiver cannot be paid is marked failed,
+// only an auth-service that cannot be reached is returned as an error.
+func ResolveBatchItems(items []models.BatchTransferItem) ([]models.TransferBatchItem, error) {
+	resolved := make([]models.TransferBatchItem, len(items))
+	for i, item := range items {
+		resolved[i] = models.TransferBatchItem{
+			Position:    i + 1,
+			Receiver:    batchReceiverLabel(item),
+			Amount:      item.Amount,
+			Description: item.Description,
+		}
+
+		receiver, err := ResolveReceiver(item.ReceiverID, item.ReceiverEmail, item.ReceiverHandle)
+		if errors.Is(err, ErrUserLookupFailed) {
+			return nil, err
+		}
+		if err != nil {
+			resolved[i].Status = BatchItemFailed
+			resolved[i].Error = err.Error()
+			continue
+		}
+		receiverID := receiver.ID
+		resolved[i].ReceiverID = &receiverID
+	}
+	return resolved, nil
+}
+
+// batchReceiverLabel is the receiver as the sender gave it
+func batchReceiverLabel(item models.BatchTransferItem) string {
+	switch {
+	case item.ReceiverEmail != "":
+		return item.ReceiverEmail
+	case item.ReceiverHandle != "":
+		return item.ReceiverHandle
+	case item.ReceiverID != 0:
+		return strconv.Itoa(item.ReceiverID)
+	default:
+		return ""
+	}
+}
+
+// ExecuteBatch transfers every item resolved by ResolveBatchItems through the same code path as POST /transfer,
+// so limits and wallet statuses apply to each of them, and records the batch and the outcome of each item.
+// The sender must be able to pay the whole batch before anything is transferred.
+func ExecuteBatch(tx *sql.Tx, senderID int, mode string, items []models.TransferBatchItem) (models.TransferBatch, error) {
+	if mode == "" {
+		mode = BatchAtomic
+	}
+	// Work on a copy, the transaction may be retried with the items as they were resolved
+	items = append([]models.TransferBatchItem(nil), items...)
+	batch := models.TransferBatch{SenderID: senderID, Mode: mode, ItemCount: len(items), Items: items}
+
+	var total models.Money
+	userIDs := []int{senderID}
+	for _, item := range items {
+		if item.Status == BatchItemFailed {
+			if mode == BatchAtomic {
+				return batch, &BatchRejectedError{Items: rejectBatchItems(items)}
+			}
+			continue
+		}
+		total += item.Amount
+		userIDs = append(userIDs, *item.ReceiverID)
+	}
+
+	// Lock every wallet of the batch in user_id order up front, the transfers below lock them again in their own order
+	if err := lockWallets(tx, userIDs...); err != nil {
+		return batch, err
+	}
+
+	var balance models.Money
+	err := tx.QueryRow("SELECT balance FROM wallets WHERE user_id = $1", senderID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return batch, ErrWalletNotFound
+	}
+	if err != nil {
+		return batch, err
+	}
+	if balance < total {
+		return batch, ErrInsufficientFunds
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO transfer_batches (sender_id, mode, status, item_count) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		senderID, mode, BatchFailed, len(items),
+	).Scan(&batch.ID, &batch.CreatedAt)
+	if err != nil {
+		return batch, err
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.Status == BatchItemFailed {
+			continue
+		}
+
+		description := item.Description
+		if description == "" {
+			description = fmt.Sprintf("Batch transfer #%d", batch.ID)
+		}
+
+		// In best effort mode each transfer runs in a savepoint so a failed one does not undo the others
+		if mode == BatchBestEffort {
+			if _, err = tx.Exec("SAVEPOINT batch_item"); err != nil {
+				return batch, err
+			}
+		}
+
+		transaction, transferErr := Transfer(tx, senderID, *item.ReceiverID, item.Amount, description)
+		if outcome := classifyRunError(transferErr); outcome != outcomeSucceeded {
+			if outcome == outcomeUnexpected || outcome == outcomeUnavailable {
+				return batch, transferErr
+			}
+
+			item.Status = BatchItemFailed
+			item.Error = transferErr.Error()
+			if mode == BatchAtomic {
+				return batch, &BatchRejectedError{Items: rejectBatchItems(items)}
+			}
+			if _, err = tx.Exec("ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+				return batch, err
+			}
+			continue
+		}
+
+		if _, err = tx.Exec("UPDATE transactions SET batch_id = $1 WHERE id = $2", batch.ID, transaction.ID); err != nil {
+			return batch, err
+		}
+		if mode == BatchBestEffort {
+			if _, err = tx.Exec("RELEASE SAVEPOINT batch_item"); err != nil {
+				return batch, err
+			}
+		}
+
+		transactionID := transaction.ID
+		item.Status = BatchItemCompleted
+		item.TransactionID = &transactionID
+		batch.TotalAmount += item.Amount
+		batch.SucceededCount++
+	}
+	batch.FailedCount = batch.ItemCount - batch.SucceededCount
+
+	for _, item := range items {
+		_, err = tx.Exec(
+			`INSERT INTO transfer_batch_items (batch_id, position, receiver, receiver_id, amount, description, status, transaction_id, error)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))`,
+			batch.ID, item.Position, item.Receiver, item.ReceiverID, item.Amount, item.Description, item.Status, item.TransactionID, item.Error,
+		)
+		if err != nil {
+			return batch, err
+		}
+	}
+
+	switch batch.SucceededCount {
+	case batch.ItemCount:
+		batch.Status = BatchCompleted
+	case 0:
+		batch.Status = BatchFailed
+	default:
+		batch.Status = BatchPartial
+	}
+	_, err = tx.Exec(
+		"UPDATE transfer_batches SET status = $1, total_amount = $2, succeeded_count = $3 WHERE id = $4",
+		batch.Status, batch.TotalAmount, batch.SucceededCount, batch.ID,
+	)
+	return batch, err
+}
+
+// rejectBatchItems marks every item of a rejected atomic batch that did not fail itself as not executed
+func rejectBatchItems(items []models.TransferBatchItem) []models.TransferBatchItem {
+	rejected := make([]models.TransferBatchItem, len(items))
+	for i, item := range items {
+		if item.Status != BatchItemFailed {
+			item.Status = BatchItemNotExecuted
+			item.TransactionID = nil
+		}
+		rejected[i] = item
+	}
+	return rejected
+}
+
+// GetTransferBatch returns one of the sender's batches with the outcome of every item
+func GetTransferBatch(db *sql.DB, id, senderID int) (models.TransferBatch, error) {
+	var batch models.TransferBatch
+	err := ScanTransferBatch(db.QueryRow(
+		"SELECT "+TransferBatchColumns+" FROM transfer_batches WHERE id = $1 AND sender_id = $2",
+		id, senderID,
+	), &batch)
+	if err == sql.ErrNoRows {
+		return batch, ErrBatchNotFound
+	}
+	if err != nil {
+		return batch, err
+	}
+
+	rows, err := db.Query(
+		`SELECT position, receiver, receiver_id, amount, description, status, transaction_id, COALESCE(error, '')
+		 FROM transfer_batch_items
+		 WHERE batch_id = $1
+		 ORDER BY position`,
+		id,
+	)
+	if err != nil {
+		return batch, err
+	}
+	defer rows.Close()
+
+	batch.Items = []models.TransferBatchItem{}
+	for rows.Next() {
+		var item models.TransferBatchItem
+		if err := rows.Scan(&item.Position, &item.Receiver, &item.ReceiverID, &item.Amount, &item.Description, &item.Status, &item.TransactionID, &item.Error); err != nil {
+			return batch, err
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	return batch, rows.Err()
+}
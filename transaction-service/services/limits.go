@@ -0,0 +1,225 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"transaction-service/models"
+)
+
+// DefaultTier is the limit tier of new wallets
+const DefaultTier = "standard"
+
+// Names of the limits, reported in LimitExceededError.Limit
+const (
+	LimitPerTransaction = "max_per_transaction"
+	LimitDailyAmount    = "daily_amount"
+	LimitDailyCount     = "daily_count"
+	LimitMonthlyAmount  = "monthly_amount"
+	LimitMonthlyCount   = "monthly_count"
+)
+
+var ErrTierNotFound = errors.New("limit tier not found")
+
+// LimitExceededError says which limit an outgoing payment would break and when the window starts over.
+// Amount limits fill in Max and Used, count limits MaxCount and UsedCount.
+type LimitExceededError struct {
+	Limit     string        `json:"limit"`
+	Tier      string        `json:"tier"`
+	Max       *models.Money `json:"max,omitempty"`
+	Used      *models.Money `json:"used,omitempty"`
+	MaxCount  *int          `json:"max_count,omitempty"`
+	UsedCount *int          `json:"used_count,omitempty"`
+	ResetsAt  *time.Time    `json:"resets_at,omitempty"`
+}
+
+func (e *LimitExceededError) Error() string {
+	switch e.Limit {
+	case LimitPerTransaction:
+		return fmt.Sprintf("Amount exceeds the limit of %s per transaction", e.Max)
+	case LimitDailyAmount:
+		return fmt.Sprintf("Daily limit of %s would be exceeded, %s already sent today", e.Max, e.Used)
+	case LimitMonthlyAmount:
+		return fmt.Sprintf("Monthly limit of %s would be exceeded, %s already sent this month", e.Max, e.Used)
+	case LimitDailyCount:
+		return fmt.Sprintf("Daily limit of %d outgoing transactions reached", *e.MaxCount)
+	default:
+		return fmt.Sprintf("Monthly limit of %d outgoing transactions reached", *e.MaxCount)
+	}
+}
+
+// limitTierColumns lists the limit_tiers columns in the order scanLimitTier reads them
+const limitTierColumns = "tier, max_per_transaction, daily_amount, daily_count, monthly_amount, monthly_count, updated_at"
+
+func scanLimitTier(row scanner, t *models.LimitTier) error {
+	return row.Scan(&t.Tier, &t.MaxPerTransaction, &t.DailyAmount, &t.DailyCount, &t.MonthlyAmount, &t.MonthlyCount, &t.UpdatedAt)
+}
+
+// ListLimitTiers returns every tier with its limits
+func ListLimitTiers(db *sql.DB) ([]models.LimitTier, error) {
+	rows, err := db.Query("SELECT " + limitTierColumns + " FROM limit_tiers ORDER BY tier")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []models.LimitTier{}
+	for rows.Next() {
+		var tier models.LimitTier
+		if err := scanLimitTier(rows, &tier); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, rows.Err()
+}
+
+// SaveLimitTier creates a tier or replaces all of its limits on behalf of an admin and records why
+func SaveLimitTier(tx *sql.Tx, adminID int, name string, req models.UpdateLimitTierRequest) (models.LimitTier, error) {
+	var previous *models.LimitTier
+	var existing models.LimitTier
+	err := scanLimitTier(tx.QueryRow("SELECT "+limitTierColumns+" FROM limit_tiers WHERE tier = $1 FOR UPDATE", name), &existing)
+	if err != nil && err != sql.ErrNoRows {
+		return models.LimitTier{}, err
+	}
+	if err == nil {
+		previous = &existing
+	}
+
+	var tier models.LimitTier
+	err = scanLimitTier(tx.QueryRow(
+		`INSERT INTO limit_tiers (tier, max_per_transaction, daily_amount, daily_count, monthly_amount, monthly_count)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (tier) DO UPDATE SET
+			max_per_transaction = EXCLUDED.max_per_transaction,
+			daily_amount = EXCLUDED.daily_amount,
+			daily_count = EXCLUDED.daily_count,
+			monthly_amount = EXCLUDED.monthly_amount,
+			monthly_count = EXCLUDED.monthly_count,
+			updated_at = NOW()
+		 RETURNING `+limitTierColumns,
+		name, req.MaxPerTransaction, req.DailyAmount, req.DailyCount, req.MonthlyAmount, req.MonthlyCount,
+	), &tier)
+	if err != nil {
+		return tier, err
+	}
+
+	err = RecordAdminAction(tx, models.AuditLogEntry{
+		AdminUserID: adminID,
+		Action:      AuditLimitTierSaved,
+		Reason:      req.Reason,
+	}, map[string]interface{}{"tier": name, "previous_limits": previous, "limits": tier})
+	return tier, err
+}
+
+// SetWalletTier moves a wallet to another limit tier on behalf of an admin and records why
+func SetWalletTier(tx *sql.Tx, adminID, userID int, tier, reason string) (models.Wallet, error) {
+	var tierExists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM limit_tiers WHERE tier = $1)", tier).Scan(&tierExists); err != nil {
+		return models.Wallet{}, err
+	}
+	if !tierExists {
+		return models.Wallet{}, ErrTierNotFound
+	}
+
+	var previous string
+	err := tx.QueryRow("SELECT tier FROM wallets WHERE user_id = $1 FOR UPDATE", userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return models.Wallet{}, ErrWalletNotFound
+	}
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	var wallet models.Wallet
+	err = ScanWallet(tx.QueryRow(
+		`UPDATE wallets SET tier = $1, updated_at = NOW() WHERE user_id = $2 RETURNING `+WalletColumns,
+		tier, userID,
+	), &wallet)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	err = RecordAdminAction(tx, models.AuditLogEntry{
+		AdminUserID: adminID,
+		Action:      AuditWalletTierChanged,
+		UserID:      userID,
+		Reason:      reason,
+	}, map[string]interface{}{"previous_tier": previous, "tier": tier})
+	return wallet, err
+}
+
+// GetLimitUsage returns the limits of the user's tier and what the user has sent in the current day and month.
+// Transfers, including pending ones, and withdrawals count as outgoing, refunds do not.
+// Days and months follow the database clock. created_at has no time zone, so the windows start at LOCALTIMESTAMP,
+// while the reset times are computed from NOW() to carry the database's UTC offset.
+func GetLimitUsage(q queryer, userID int) (models.LimitUsage, error) {
+	var usage models.LimitUsage
+	err := scanLimitTier(q.QueryRow(
+		`SELECT `+limitTierColumns+` FROM limit_tiers
+		 WHERE tier = COALESCE((SELECT tier FROM wallets WHERE user_id = $1), $2)`,
+		userID, DefaultTier,
+	), &usage.Limits)
+	if err != nil {
+		return usage, err
+	}
+
+	err = q.QueryRow(
+		`SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= date_trunc('day', LOCALTIMESTAMP)), 0),
+			COUNT(*) FILTER (WHERE created_at >= date_trunc('day', LOCALTIMESTAMP)),
+			COALESCE(SUM(amount), 0),
+			COUNT(*),
+			date_trunc('day', NOW()) + INTERVAL '1 day',
+			date_trunc('month', NOW()) + INTERVAL '1 month'
+		 FROM transactions
+		 WHERE sender_id = $1
+		   AND created_at >= date_trunc('month', LOCALTIMESTAMP)
+		   AND transaction_type IN ('transfer', 'withdrawal')
+		   AND status IN ('pending', 'completed')`,
+		userID,
+	).Scan(&usage.DailyAmount, &usage.DailyCount, &usage.MonthlyAmount, &usage.MonthlyCount, &usage.DailyResetsAt, &usage.MonthlyResetsAt)
+	return usage, err
+}
+
+// CheckLimits makes sure an outgoing payment of amount stays within the limits of the user's tier.
+// The caller must hold the lock on the user's wallet so concurrent payments are counted one after another.
+func CheckLimits(tx *sql.Tx, userID int, amount models.Money) error {
+	usage, err := GetLimitUsage(tx, userID)
+	if err != nil {
+		return err
+	}
+	limits := usage.Limits
+
+	exceeded := func(limit string) *LimitExceededError {
+		return &LimitExceededError{Limit: limit, Tier: limits.Tier}
+	}
+
+	if limits.MaxPerTransaction != nil && amount > *limits.MaxPerTransaction {
+		e := exceeded(LimitPerTransaction)
+		e.Max = limits.MaxPerTransaction
+		return e
+	}
+	if limits.DailyCount != nil && usage.DailyCount >= *limits.DailyCount {
+		e := exceeded(LimitDailyCount)
+		e.MaxCount, e.UsedCount, e.ResetsAt = limits.DailyCount, &usage.DailyCount, &usage.DailyResetsAt
+		return e
+	}
+	if limits.DailyAmount != nil && usage.DailyAmount+amount > *limits.DailyAmount {
+		e := exceeded(LimitDailyAmount)
+		e.Max, e.Used, e.ResetsAt = limits.DailyAmount, &usage.DailyAmount, &usage.DailyResetsAt
+		return e
+	}
+	if limits.MonthlyCount != nil && usage.MonthlyCount >= *limits.MonthlyCount {
+		e := exceeded(LimitMonthlyCount)
+		e.MaxCount, e.UsedCount, e.ResetsAt = limits.MonthlyCount, &usage.MonthlyCount, &usage.MonthlyResetsAt
+		return e
+	}
+	if limits.MonthlyAmount != nil && usage.MonthlyAmount+amount > *limits.MonthlyAmount {
+		e := exceeded(LimitMonthlyAmount)
+		e.Max, e.Used, e.ResetsAt = limits.MonthlyAmount, &usage.MonthlyAmount, &usage.MonthlyResetsAt
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/models"
+)
+
+// Payment request statuses, only pending requests can be paid, declined or cancelled
+const (
+	PaymentRequestPending   = "pending"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestDeclined  = "declined"
+	PaymentRequestCancelled = "cancelled"
+	PaymentRequestExpired   = "expired"
+)
+
+var (
+	ErrSelfPaymentRequest       = errors.New("cannot request money from yourself")
+	ErrPaymentRequestNotFound   = errors.New("payment request not found")
+	ErrPaymentRequestNotPending = errors.New("payment request is not pending")
+	ErrPaymentRequestExpired    = errors.New("payment request has expired")
+)
+
+// paymentRequestStatus reports a pending request past its expiry as expired before the expiry worker has swept it
+const paymentRequestStatus = "CASE WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired' ELSE status END"
+
+// PaymentRequestColumns lists the payment_requests columns in the order ScanPaymentRequest reads them
+const PaymentRequestColumns = "id, requester_id, payer_id, amount, description, " + paymentRequestStatus + ", transaction_id, split_id, expires_at, created_at, updated_at"
+
+// ScanPaymentRequest reads a row selected with PaymentRequestColumns
+func ScanPaymentRequest(row scanner, pr *models.PaymentRequest) error {
+	return row.Scan(&pr.ID, &pr.RequesterID, &pr.PayerID, &pr.Amount, &pr.Description, &pr.Status, &pr.TransactionID, &pr.SplitID, &pr.ExpiresAt, &pr.CreatedAt, &pr.UpdatedAt)
+}
+
+// PaymentRequestTTLHours is how long a payer has to pay a request unless the requester picks another expiry
+func PaymentRequestTTLHours() int {
+	hours, err := strconv.Atoi(config.GetEnv("PAYMENT_REQUEST_TTL_HOURS", "168"))
+	if err != nil || hours < 1 {
+		return 168
+	}
+	return hours
+}
+
+// CreatePaymentRequest asks a payer that has already been validated to pay the requester
+func CreatePaymentRequest(db *sql.DB, requesterID, payerID int, amount models.Money, description string, expiresInHours int) (models.PaymentRequest, error) {
+	if requesterID == payerID {
+		return models.PaymentRequest{}, ErrSelfPaymentRequest
+	}
+	if err := checkRequesterWallet(db, requesterID); err != nil {
+		return models.PaymentRequest{}, err
+	}
+	return insertPaymentRequest(db, requesterID, payerID, amount, description, expiresInHours, nil)
+}
+
+// checkRequesterWallet refuses requests for a closed wallet, it could never receive the payment
+func checkRequesterWallet(q queryer, requesterID int) error {
+	var walletStatus string
+	err := q.QueryRow("SELECT status FROM wallets WHERE user_id = $1", requesterID).Scan(&walletStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if walletStatus == WalletClosed {
+		return ErrWalletClosed
+	}
+	return nil
+}
+
+// insertPaymentRequest stores a pending request, splitID links the share of a bill split
+func insertPaymentRequest(q queryer, requesterID, payerID int, amount models.Money, description string, expiresInHours int, splitID *int) (models.PaymentRequest, error) {
+	if expiresInHours == 0 {
+		expiresInHours = PaymentRequestTTLHours()
+	}
+
+	// expires_at is computed by the database so it compares cleanly with NOW()
+	var pr models.PaymentRequest
+	err := ScanPaymentRequest(q.QueryRow(
+		`INSERT INTO payment_requests (requester_id, payer_id, amount, description, split_id, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW() + make_interval(hours => $6))
+		 RETURNING `+PaymentRequestColumns,
+		requesterID, payerID, amount, description, splitID, expiresInHours,
+	), &pr)
+	return pr, err
+}
+
+// ListPaymentRequests returns the requests the user was asked to pay (incoming) or sent (outgoing), newest first,
+// optionally only those with the given status
+func ListPaymentRequests(db *sql.DB, userID int, incoming bool, status string) ([]models.PaymentRequest, error) {
+	party := "requester_id"
+	if incoming {
+		party = "payer_id"
+	}
+
+	rows, err := db.Query(
+		`SELECT `+PaymentRequestColumns+`
+		 FROM payment_requests
+		 WHERE `+party+` = $1 AND ($2 = '' OR `+paymentRequestStatus+` = $2)
+		 ORDER BY created_at DESC, id DESC`,
+		userID, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.PaymentRequest{}
+	for rows.Next() {
+		var pr models.PaymentRequest
+		if err := ScanPaymentRequest(rows, &pr); err != nil {
+			return nil, err
+		}
+		requests = append(requests, pr)
+	}
+	return requests, rows.Err()
+}
+
+// GetPaymentRequest returns a payment request the user sent or was asked to pay
+func GetPaymentRequest(db *sql.DB, id, userID int) (models.PaymentRequest, error) {
+	var pr models.PaymentRequest
+	err := ScanPaymentRequest(db.QueryRow(
+		"SELECT "+PaymentRequestColumns+" FROM payment_requests WHERE id = $1 AND (requester_id = $2 OR payer_id = $2)",
+		id, userID,
+	), &pr)
+	if err == sql.ErrNoRows {
+		return pr, ErrPaymentRequestNotFound
+	}
+	return pr, err
+}
+
+// lockPendingPaymentRequest locks a pending request where the user is the given party, requester_id or payer_id
+func lockPendingPaymentRequest(tx *sql.Tx, id int, party string, userID int) (models.PaymentRequest, error) {
+	var pr models.PaymentRequest
+	err := ScanPaymentRequest(tx.QueryRow(
+		"SELECT "+PaymentRequestColumns+" FROM payment_requests WHERE id = $1 AND "+party+" = $2 FOR UPDATE",
+		id, userID,
+	), &pr)
+	if err == sql.ErrNoRows {
+		return pr, ErrPaymentRequestNotFound
+	}
+	if err != nil {
+		return pr, err
+	}
+	if pr.Status == PaymentRequestExpired {
+		return pr, ErrPaymentRequestExpired
+	}
+	if pr.Status != PaymentRequestPending {
+		return pr, ErrPaymentRequestNotPending
+	}
+	return pr, nil
+}
+
+// PayPaymentRequest pays a pending request with a regular transfer from the payer to the requester
+// and links the transfer to the request. Limits and wallet statuses apply like for any transfer.
+func PayPaymentRequest(tx *sql.Tx, id, payerID int) (models.PaymentRequest, models.Transaction, error) {
+	pr, err := lockPendingPaymentRequest(tx, id, "payer_id", payerID)
+	if err != nil {
+		return pr, models.Transaction{}, err
+	}
+
+	// The requester may have been disabled since asking
+	if _, err = ValidateReceiver(pr.RequesterID); err != nil {
+		return pr, models.Transaction{}, err
+	}
+
+	description := fmt.Sprintf("Payment request #%d", pr.ID)
+	if pr.Description != "" {
+		description += ": " + pr.Description
+	}
+	transaction, err := Transfer(tx, pr.PayerID, pr.RequesterID, pr.Amount, description)
+	if err != nil {
+		return pr, transaction, err
+	}
+
+	err = ScanPaymentRequest(tx.QueryRow(
+		`UPDATE payment_requests SET status = 'paid', transaction_id = $1, updated_at = NOW()
+		 WHERE id = $2
+		 RETURNING `+PaymentRequestColumns,
+		transaction.ID, pr.ID,
+	), &pr)
+	if err != nil {
+		return pr, transaction, err
+	}
+
+	if pr.SplitID != nil {
+		err = closeBillSplitIfDone(tx, *pr.SplitID)
+	}
+	return pr, transaction, err
+}
+
+// DeclinePaymentRequest lets the payer refuse a pending request
+func DeclinePaymentRequest(tx *sql.Tx, id, payerID int) (models.PaymentRequest, error) {
+	pr, err := lockPendingPaymentRequest(tx, id, "payer_id", payerID)
+	if err != nil {
+		return pr, err
+	}
+	return setPaymentRequestStatus(tx, pr.ID, PaymentRequestDeclined)
+}
+
+// CancelPaymentRequest lets the requester withdraw a pending request
+func CancelPaymentRequest(tx *sql.Tx, id, requesterID int) (models.PaymentRequest, error) {
+	pr, err := lockPendingPaymentRequest(tx, id, "requester_id", requesterID)
+	if err != nil {
+		return pr, err
+	}
+	return setPaymentRequestStatus(tx, pr.ID, PaymentRequestCancelled)
+}
+
+func setPaymentRequestStatus(tx *sql.Tx, id int, status string) (models.PaymentRequest, error) {
+	var pr models.PaymentRequest
+	err := ScanPaymentRequest(tx.QueryRow(
+		"UPDATE payment_requests SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING "+PaymentRequestColumns,
+		status, id,
+	), &pr)
+	if err == nil && pr.SplitID != nil {
+		err = closeBillSplitIfDone(tx, *pr.SplitID)
+	}
+	return pr, err
+}
+
+// ExpirePaymentRequests marks every pending request past its expiry as expired and closes the bill splits
+// that have nothing left to pay because of it
+func ExpirePaymentRequests(db *sql.DB) (int64, error) {
+	result, err := db.Exec("UPDATE payment_requests SET status = 'expired', updated_at = NOW() WHERE status = 'pending' AND expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = db.Exec(closeBillSplitsQuery)
+	return expired, err
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"transaction-service/models"
+)
+
+// Payout file statuses, a file is executed at most once
+const (
+	PayoutValidated = "validated"
+	PayoutExecuted  = "executed"
+)
+
+// PayoutRowValid marks a dry-run row that passed every check
+const PayoutRowValid = "valid"
+
+// A payout file is executed as one batch, so it has the same size limit
+const maxPayoutRows = 500
+
+var (
+	ErrInvalidPayoutFile     = errors.New("invalid payout file")
+	ErrPayoutNotFound        = errors.New("payout not found")
+	ErrPayoutAlreadyExecuted = errors.New("payout has already been executed")
+	ErrPayoutNotExecuted     = errors.New("payout has not been executed yet")
+)
+
+// PayoutColumns lists the payout_files columns in the order ScanPayout reads them
+const PayoutColumns = "id, sender_id, file_name, status, row_count, valid_count, total_amount, batch_id, created_at, executed_at"
+
+// ScanPayout reads a row selected with PayoutColumns
+func ScanPayout(row scanner, payout *models.Payout) error {
+	err := row.Scan(&payout.ID, &payout.SenderID, &payout.FileName, &payout.Status, &payout.RowCount, &payout.ValidCount,
+		&payout.TotalAmount, &payout.BatchID, &payout.CreatedAt, &payout.ExecutedAt)
+	payout.ErrorCount = payout.RowCount - payout.ValidCount
+	return err
+}
+
+// PreparePayoutItems reads a payout CSV and looks up every receiver. The file needs a header row with
+// a receiver column (an email or a user id), an amount column and optionally a description column.
+// Each item's position is its line in the file, rows that cannot be paid come back failed with the reason.
+func PreparePayoutItems(content string) ([]models.TransferBatchItem, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidPayoutFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayoutFile, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	receiverColumn, hasReceiver := columns["receiver"]
+	amountColumn, hasAmount := columns["amount"]
+	descriptionColumn, hasDescription := columns["description"]
+	if !hasReceiver || !hasAmount {
+		return nil, fmt.Errorf("%w: the header must name a receiver and an amount column", ErrInvalidPayoutFile)
+	}
+
+	field := func(record []string, column int) string {
+		if column < len(record) {
+			return strings.TrimSpace(record[column])
+		}
+		return ""
+	}
+
+	var items []models.TransferBatchItem
+	// The rows that parsed, resolved together below, and where they go in items
+	var toResolve []models.BatchTransferItem
+	var resolveAt []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayoutFile, err)
+		}
+		if len(items) == maxPayoutRows {
+			return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidPayoutFile, maxPayoutRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		item := models.TransferBatchItem{Position: line, Receiver: field(record, receiverColumn)}
+		if hasDescription {
+			item.Description = field(record, descriptionColumn)
+		}
+
+		request := models.BatchTransferItem{Description: item.Description}
+		item.Amount, err = models.ParseMoney(field(record, amountColumn))
+		switch {
+		case err != nil:
+			item.Error = err.Error()
+		case item.Amount <= 0:
+			item.Error = "amount must be greater than zero"
+		case strings.Contains(item.Receiver, "@"):
+			request.ReceiverEmail = item.Receiver
+		default:
+			request.ReceiverID, err = strconv.Atoi(item.Receiver)
+			if err != nil || request.ReceiverID <= 0 {
+				item.Error = "receiver must be an email or a user id"
+			}
+		}
+
+		if item.Error != "" {
+			item.Status = BatchItemFailed
+		} else {
+			request.Amount = item.Amount
+			toResolve = append(toResolve, request)
+			resolveAt = append(resolveAt, len(items))
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rows", ErrInvalidPayoutFile)
+	}
+
+	resolved, err := ResolveBatchItems(toResolve)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range resolved {
+		item.Position = items[resolveAt[i]].Position
+		items[resolveAt[i]] = item
+	}
+	return items, nil
+}
+
+// CreatePayout checks a payout file without moving any money and stores it for ExecutePayout.
+// The returned rows say which of them would fail and why.
+func CreatePayout(db *sql.DB, senderID int, fileName, content string) (models.Payout, error) {
+	items, err := PreparePayoutItems(content)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout := models.Payout{SenderID: senderID, FileName: fileName, Status: PayoutValidated, RowCount: len(items), Rows: items}
+	for i := range payout.Rows {
+		if payout.Rows[i].Status == BatchItemFailed {
+			payout.ErrorCount++
+			continue
+		}
+		payout.Rows[i].Status = PayoutRowValid
+		payout.ValidCount++
+		payout.TotalAmount += payout.Rows[i].Amount
+	}
+
+	var balance models.Money
+	err = db.QueryRow("SELECT balance FROM wallets WHERE user_id = $1", senderID).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return payout, err
+	}
+	sufficientFunds := balance >= payout.TotalAmount
+	payout.SufficientFunds = &sufficientFunds
+
+	err = db.QueryRow(
+		`INSERT INTO payout_files (sender_id, file_name, content, row_count, valid_count, total_amount)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, created_at`,
+		senderID, fileName, content, payout.RowCount, payout.ValidCount, payout.TotalAmount,
+	).Scan(&payout.ID, &payout.CreatedAt)
+	return payout, err
+}
+
+// PreparePayoutExecution reads a stored payout file that has not been executed yet and looks up its receivers again,
+// they may have changed since the dry run
+func PreparePayoutExecution(db *sql.DB, id, senderID int) ([]models.TransferBatchItem, error) {
+	var status, content string
+	err := db.QueryRow("SELECT status, content FROM payout_files WHERE id = $1 AND sender_id = $2", id, senderID).Scan(&status, &content)
+	if err == sql.ErrNoRows {
+		return nil, ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != PayoutValidated {
+		return nil, ErrPayoutAlreadyExecuted
+	}
+	return PreparePayoutItems(content)
+}
+
+// ExecutePayout makes the transfers of a payout file as one batch and marks the file executed
+func ExecutePayout(tx *sql.Tx, id, senderID int, mode string, items []models.TransferBatchItem) (models.TransferBatch, error) {
+	// Lock the file so it cannot be executed twice at the same time
+	var status string
+	err := tx.QueryRow("SELECT status FROM payout_files WHERE id = $1 AND sender_id = $2 FOR UPDATE", id, senderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.TransferBatch{}, ErrPayoutNotFound
+	}
+	if err != nil {
+		return models.TransferBatch{}, err
+	}
+	if status != PayoutValidated {
+		return models.TransferBatch{}, ErrPayoutAlreadyExecuted
+	}
+
+	batch, err := ExecuteBatch(tx, senderID, mode, items)
+	if err != nil {
+		return batch, err
+	}
+
+	_, err = tx.Exec("UPDATE payout_files SET status = 'executed', batch_id = $1, executed_at = NOW() WHERE id = $2", batch.ID, id)
+	return batch, err
+}
+
+// GetPayoutBatch returns the batch an executed payout file was paid with
+func GetPayoutBatch(db *sql.DB, id, senderID int) (models.TransferBatch, error) {
+	var payout models.Payout
+	err := ScanPayout(db.QueryRow("SELECT "+PayoutColumns+" FROM payout_files WHERE id = $1 AND sender_id = $2", id, senderID), &payout)
+	if err == sql.ErrNoRows {
+		return models.TransferBatch{}, ErrPayoutNotFound
+	}
+	if err != nil {
+		return models.TransferBatch{}, err
+	}
+	if payout.BatchID == nil {
+		return models.TransferBatch{}, ErrPayoutNotExecuted
+	}
+	return GetTransferBatch(db, *payout.BatchID, senderID)
+}
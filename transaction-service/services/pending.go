@@ -0,0 +1,232 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"transaction-service/config"
+	"transaction-service/ledger"
+	"transaction-service/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrTransferNotPending  = errors.New("transfer is not pending")
+	ErrTransferExpired     = errors.New("transfer has expired")
+)
+
+// PendingTransferTTLHours is how long a receiver has to accept a pending transfer
+func PendingTransferTTLHours() int {
+	hours, err := strconv.Atoi(config.GetEnv("PENDING_TRANSFER_TTL_HOURS", "72"))
+	if err != nil || hours < 1 {
+		return 72
+	}
+	return hours
+}
+
+// CreatePendingTransfer takes the funds from the sender and holds them in the pending transfers account
+// until the receiver accepts or declines, or the transfer expires.
+func CreatePendingTransfer(tx *sql.Tx, senderID, receiverID int, amount models.Money, description string) (models.Transaction, error) {
+	if senderID == receiverID {
+		return models.Transaction{}, ErrSelfTransfer
+	}
+
+	// A closed wallet could never accept, refuse now instead of holding the funds until expiry
+	var receiverStatus string
+	err := tx.QueryRow("SELECT status FROM wallets WHERE user_id = $1", receiverID).Scan(&receiverStatus)
+	if err != nil && err != sql.ErrNoRows {
+		return models.Transaction{}, err
+	}
+	if receiverStatus == WalletClosed {
+		return models.Transaction{}, ErrReceiverWalletClosed
+	}
+
+	if err = lockWallets(tx, senderID); err != nil {
+		return models.Transaction{}, err
+	}
+	if err = CheckLimits(tx, senderID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if _, err = DebitWallet(tx, senderID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	// expires_at is computed by the database so it compares cleanly with NOW() in the expiry worker
+	var transaction models.Transaction
+	err = ScanTransaction(tx.QueryRow(
+		`INSERT INTO transactions (sender_id, receiver_id, amount, status, transaction_type, description, expires_at)
+		 VALUES ($1, $2, $3, 'pending', 'transfer', $4, NOW() + make_interval(hours => $5))
+		 RETURNING `+TransactionColumns,
+		senderID, receiverID, amount, description, PendingTransferTTLHours(),
+	), &transaction)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	senderAccountID, err := ledger.WalletAccount(tx, senderID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	holdingAccountID, err := ledger.SystemAccount(tx, ledger.PendingTransfers)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if err = ledger.Move(tx, transaction.ID, "Pending transfer hold", senderAccountID, holdingAccountID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transaction, nil
+}
+
+// AcceptPendingTransfer releases the held funds into the receiver's wallet
+func AcceptPendingTransfer(tx *sql.Tx, transactionID, receiverID int) (models.Transaction, error) {
+	transaction, err := lockPendingTransfer(tx, transactionID, receiverID)
+	if err != nil {
+		return transaction, err
+	}
+
+	var expired bool
+	err = tx.QueryRow("SELECT expires_at <= NOW() FROM transactions WHERE id = $1", transaction.ID).Scan(&expired)
+	if err != nil {
+		return transaction, err
+	}
+	if expired {
+		return transaction, ErrTransferExpired
+	}
+
+	if _, err = CreditWallet(tx, transaction.ReceiverID, transaction.Amount); err != nil {
+		return transaction, err
+	}
+
+	holdingAccountID, err := ledger.SystemAccount(tx, ledger.PendingTransfers)
+	if err != nil {
+		return transaction, err
+	}
+	receiverAccountID, err := ledger.WalletAccount(tx, transaction.ReceiverID)
+	if err != nil {
+		return transaction, err
+	}
+	if err = ledger.Move(tx, transaction.ID, "Pending transfer accepted", holdingAccountID, receiverAccountID, transaction.Amount); err != nil {
+		return transaction, err
+	}
+
+	return setTransactionStatus(tx, transaction.ID, "completed")
+}
+
+// DeclinePendingTransfer sends the held funds back to the sender
+func DeclinePendingTransfer(tx *sql.Tx, transactionID, receiverID int) (models.Transaction, error) {
+	transaction, err := lockPendingTransfer(tx, transactionID, receiverID)
+	if err != nil {
+		return transaction, err
+	}
+	return returnPendingTransfer(tx, transaction, "declined")
+}
+
+// ExpirePendingTransfers returns the funds of every pending transfer past its expiry to the sender.
+// Each transfer is expired in its own database transaction and rows locked by an accept or decline are skipped.
+// A transfer that fails to expire is left for the next sweep, the others are still expired.
+func ExpirePendingTransfers(db *sql.DB) (int, error) {
+	expired := 0
+	var failures []error
+	// Transfers that failed in this sweep, so they are not picked again
+	failed := []int64{}
+	for {
+		var transactionID int
+		err := RunInTx(db, func(tx *sql.Tx) error {
+			transactionID = 0
+			var transaction models.Transaction
+			err := ScanTransaction(tx.QueryRow(
+				`SELECT `+TransactionColumns+`
+				 FROM transactions
+				 WHERE status = 'pending' AND expires_at <= NOW() AND id <> ALL($1)
+				 ORDER BY expires_at
+				 LIMIT 1
+				 FOR UPDATE SKIP LOCKED`,
+				pq.Array(failed),
+			), &transaction)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			transactionID = transaction.ID
+			_, err = returnPendingTransfer(tx, transaction, "expired")
+			return err
+		})
+		if err != nil && transactionID != 0 {
+			log.Printf("Failed to expire pending transfer %d: %v", transactionID, err)
+			failures = append(failures, fmt.Errorf("pending transfer %d: %w", transactionID, err))
+			failed = append(failed, int64(transactionID))
+			continue
+		}
+		if err != nil {
+			return expired, errors.Join(append(failures, err)...)
+		}
+		if transactionID == 0 {
+			return expired, errors.Join(failures...)
+		}
+
+		expired++
+	}
+}
+
+// lockPendingTransfer locks a pending transfer addressed to the receiver
+func lockPendingTransfer(tx *sql.Tx, transactionID, receiverID int) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := ScanTransaction(tx.QueryRow(
+		`SELECT `+TransactionColumns+`
+		 FROM transactions
+		 WHERE id = $1 AND receiver_id = $2 AND transaction_type = 'transfer'
+		 FOR UPDATE`,
+		transactionID, receiverID,
+	), &transaction)
+	if err == sql.ErrNoRows {
+		return transaction, ErrTransactionNotFound
+	}
+	if err != nil {
+		return transaction, err
+	}
+	if transaction.Status != "pending" {
+		return transaction, ErrTransferNotPending
+	}
+	return transaction, nil
+}
+
+// returnPendingTransfer credits the held funds back to the sender and closes the transfer with the given status
+func returnPendingTransfer(tx *sql.Tx, transaction models.Transaction, status string) (models.Transaction, error) {
+	if _, err := CreditWallet(tx, transaction.SenderID, transaction.Amount); err != nil {
+		return transaction, err
+	}
+
+	holdingAccountID, err := ledger.SystemAccount(tx, ledger.PendingTransfers)
+	if err != nil {
+		return transaction, err
+	}
+	senderAccountID, err := ledger.WalletAccount(tx, transaction.SenderID)
+	if err != nil {
+		return transaction, err
+	}
+	if err = ledger.Move(tx, transaction.ID, "Pending transfer "+status, holdingAccountID, senderAccountID, transaction.Amount); err != nil {
+		return transaction, err
+	}
+
+	return setTransactionStatus(tx, transaction.ID, status)
+}
+
+func setTransactionStatus(tx *sql.Tx, transactionID int, status string) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := ScanTransaction(tx.QueryRow(
+		`UPDATE transactions SET status = $1, updated_at = NOW()
+		 WHERE id = $2
+		 RETURNING `+TransactionColumns,
+		status, transactionID,
+	), &transaction)
+	return transaction, err
+}
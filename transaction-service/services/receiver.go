@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"transaction-service/clients"
+	"transaction-service/models"
+)
+
+// UserDisabled is the status the auth-service reports for a user an admin has disabled
+const UserDisabled = "disabled"
+
+var (
+	ErrReceiverRequired    = errors.New("exactly one of receiver_id, receiver_email or receiver_handle is required")
+	ErrReceiverNotFound    = errors.New("receiver not found")
+	ErrReceiverNotVerified = errors.New("receiver has not verified their email")
+	ErrReceiverDisabled    = errors.New("receiver account is disabled")
+	ErrUserLookupFailed    = errors.New("failed to look up user")
+
+	ErrPayerRequired    = errors.New("exactly one of payer_id, payer_email or payer_handle is required")
+	ErrPayerNotFound    = errors.New("payer not found")
+	ErrPayerNotVerified = errors.New("payer has not verified their email")
+	ErrPayerDisabled    = errors.New("payer account is disabled")
+)
+
+// ValidateReceiver makes sure money only goes to users that exist and have verified their email
+func ValidateReceiver(userID int) (models.User, error) {
+	return checkReceiver(clients.Users.GetUser(userID))
+}
+
+// ResolveReceiver finds the receiver from exactly one of its id, email or handle and validates it
+func ResolveReceiver(receiverID int, email, handle string) (models.User, error) {
+	given := 0
+	for _, set := range []bool{receiverID != 0, email != "", handle != ""} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return models.User{}, ErrReceiverRequired
+	}
+
+	switch {
+	case email != "":
+		return checkReceiver(clients.Users.FindUserByEmail(email))
+	case handle != "":
+		return checkReceiver(clients.Users.FindUserByHandle(handle))
+	default:
+		return ValidateReceiver(receiverID)
+	}
+}
+
+// ResolvePayer finds the user asked to pay a payment request, with the same rules as ResolveReceiver
+func ResolvePayer(payerID int, email, handle string) (models.User, error) {
+	payer, err := ResolveReceiver(payerID, email, handle)
+	switch {
+	case errors.Is(err, ErrReceiverRequired):
+		return payer, ErrPayerRequired
+	case errors.Is(err, ErrReceiverNotFound):
+		return payer, ErrPayerNotFound
+	case errors.Is(err, ErrReceiverNotVerified):
+		return payer, ErrPayerNotVerified
+	case errors.Is(err, ErrReceiverDisabled):
+		return payer, ErrPayerDisabled
+	}
+	return payer, err
+}
+
+func checkReceiver(receiver models.User, err error) (models.User, error) {
+	if errors.Is(err, clients.ErrUserNotFound) {
+		return receiver, ErrReceiverNotFound
+	}
+	if err != nil {
+		return receiver, fmt.Errorf("%w: %v", ErrUserLookupFailed, err)
+	}
+	if !receiver.IsVerified {
+		return receiver, ErrReceiverNotVerified
+	}
+	if receiver.Status == UserDisabled {
+		return receiver, ErrReceiverDisabled
+	}
+	return receiver, nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"transaction-service/models"
+)
+
+var (
+	ErrNotRefundable          = errors.New("only completed transfers you received can be refunded")
+	ErrRefundExceedsRemaining = errors.New("refund exceeds the amount left to refund")
+)
+
+// Refund gives money from a received transfer back to its sender as a linked "refund" transaction.
+// amount nil refunds everything that has not been refunded yet.
+// The original transfer is locked so concurrent refunds can never add up to more than its amount.
+func Refund(tx *sql.Tx, originalID, userID int, amount *models.Money, reason string) (models.Transaction, error) {
+	var original models.Transaction
+	err := ScanTransaction(tx.QueryRow(
+		`SELECT `+TransactionColumns+`
+		 FROM transactions
+		 WHERE id = $1 AND (sender_id = $2 OR receiver_id = $2)
+		 FOR UPDATE`,
+		originalID, userID,
+	), &original)
+	if err == sql.ErrNoRows {
+		return models.Transaction{}, ErrTransactionNotFound
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	// Only the receiver holds the money, so only the receiver can give it back
+	if original.TransactionType != "transfer" || original.Status != "completed" || original.ReceiverID != userID {
+		return models.Transaction{}, ErrNotRefundable
+	}
+
+	refunded, err := RefundedAmount(tx, original.ID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	remaining := original.Amount - refunded
+
+	refundAmount := remaining
+	if amount != nil {
+		refundAmount = *amount
+	}
+	if refundAmount <= 0 || refundAmount > remaining {
+		return models.Transaction{}, ErrRefundExceedsRemaining
+	}
+
+	description := fmt.Sprintf("Refund of transaction #%d", original.ID)
+	if reason != "" {
+		description += ": " + reason
+	}
+
+	return moveBetweenWallets(tx, models.Transaction{
+		SenderID:              original.ReceiverID,
+		ReceiverID:            original.SenderID,
+		Amount:                refundAmount,
+		Status:                "completed",
+		TransactionType:       "refund",
+		Description:           description,
+		OriginalTransactionID: &original.ID,
+	})
+}
+
+// RefundedAmount sums the completed refunds made against a transaction
+func RefundedAmount(q queryer, transactionID int) (models.Money, error) {
+	var refunded models.Money
+	err := q.QueryRow(
+		`SELECT COALESCE(SUM(amount), 0)
+		 FROM transactions
+		 WHERE original_transaction_id = $1 AND transaction_type = 'refund' AND status = 'completed'`,
+		transactionID,
+	).Scan(&refunded)
+	return refunded, err
+}
+
+// ListRefunds returns the refunds made against a transaction, oldest first
+func ListRefunds(db *sql.DB, transactionID int) ([]models.Transaction, error) {
+	rows, err := db.Query(
+		`SELECT `+TransactionColumns+`
+		 FROM transactions
+		 WHERE original_transaction_id = $1 AND transaction_type = 'refund'
+		 ORDER BY created_at, id`,
+		transactionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []models.Transaction{}
+	for rows.Next() {
+		var refund models.Transaction
+		if err := ScanTransaction(rows, &refund); err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, rows.Err()
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"transaction-service/config"
+	"transaction-service/models"
+	"transaction-service/schedule"
+)
+
+// Scheduled transfer statuses, only active schedules run
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCompleted = "completed"
+	ScheduleFailed    = "failed"
+	ScheduleCancelled = "cancelled"
+)
+
+// Outcomes recorded in scheduled_transfer_runs
+const (
+	RunCompleted = "completed"
+	RunRetrying  = "retrying"
+	RunSkipped   = "skipped"
+	RunFailed    = "failed"
+)
+
+// What a scheduled transfer does when the sender cannot pay: skip the occurrence or retry it later
+const (
+	PolicySkip  = "skip"
+	PolicyRetry = "retry"
+)
+
+const defaultScheduleMaxRetries = 3
+
+var (
+	ErrInvalidSchedule         = errors.New("invalid schedule")
+	ErrScheduleNotFound        = errors.New("scheduled transfer not found")
+	ErrScheduleFinished        = errors.New("scheduled transfer has finished")
+	ErrScheduleStatusUnchanged = errors.New("scheduled transfer already has this status")
+)
+
+// ScheduledTransferColumns lists the scheduled_transfers columns in the order ScanScheduledTransfer reads them
+const ScheduledTransferColumns = `id, sender_id, receiver_id, amount, description, frequency, COALESCE(cron_expression, ''),
+	start_at, end_at, next_run_at, status, on_insufficient_funds, max_retries, retry_count, created_at, updated_at`
+
+// ScanScheduledTransfer reads a row selected with ScheduledTransferColumns
+func ScanScheduledTransfer(row scanner, st *models.ScheduledTransfer) error {
+	return row.Scan(&st.ID, &st.SenderID, &st.ReceiverID, &st.Amount, &st.Description, &st.Frequency, &st.CronExpression,
+		&st.StartAt, &st.EndAt, &st.NextRunAt, &st.Status, &st.OnInsufficientFunds, &st.MaxRetries, &st.RetryCount, &st.CreatedAt, &st.UpdatedAt)
+}
+
+// ScheduledTransferRetryDelay is how long a retried occurrence waits before the next attempt
+func ScheduledTransferRetryDelay() time.Duration {
+	minutes, err := strconv.Atoi(config.GetEnv("SCHEDULED_TRANSFER_RETRY_MINUTES", "60"))
+	if err != nil || minutes < 1 {
+		minutes = 60
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func specOf(st models.ScheduledTransfer) schedule.Spec {
+	return schedule.Spec{Frequency: st.Frequency, Cron: st.CronExpression, StartAt: st.StartAt}
+}
+
+// nextRun returns the first occurrence at or after from that is not past the end of the schedule, nil if there is none
+func nextRun(st models.ScheduledTransfer, from time.Time) (*time.Time, error) {
+	next, err := specOf(st).Next(from)
+	if err == schedule.ErrNoOccurrence {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.EndAt != nil && next.After(*st.EndAt) {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// planSchedule validates the schedule of st and sets its first run at or after now
+func planSchedule(st *models.ScheduledTransfer, now time.Time) error {
+	if err := specOf(*st).Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	next, err := nextRun(*st, now)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if next == nil {
+		return fmt.Errorf("%w: the schedule has no run in the future", ErrInvalidSchedule)
+	}
+	st.NextRunAt = next
+	return nil
+}
+
+// CreateScheduledTransfer stores a new schedule for a receiver that has already been validated
+func CreateScheduledTransfer(db *sql.DB, senderID, receiverID int, req models.ScheduledTransferRequest) (models.ScheduledTransfer, error) {
+	if senderID == receiverID {
+		return models.ScheduledTransfer{}, ErrSelfTransfer
+	}
+
+	now := time.Now().UTC()
+	st := models.ScheduledTransfer{
+		SenderID:            senderID,
+		ReceiverID:          receiverID,
+		Amount:              req.Amount,
+		Description:         req.Description,
+		Frequency:           req.Frequency,
+		CronExpression:      req.CronExpression,
+		StartAt:             now,
+		EndAt:               req.EndAt,
+		OnInsufficientFunds: req.OnInsufficientFunds,
+		MaxRetries:          defaultScheduleMaxRetries,
+	}
+	if req.StartAt != nil {
+		// Allow for a little clock drift between client and server
+		if req.StartAt.Before(now.Add(-time.Minute)) {
+			return st, fmt.Errorf("%w: start_at must not be in the past", ErrInvalidSchedule)
+		}
+		st.StartAt = req.StartAt.UTC()
+	}
+	if st.OnInsufficientFunds == "" {
+		st.OnInsufficientFunds = PolicySkip
+	}
+	if req.MaxRetries != nil {
+		st.MaxRetries = *req.MaxRetries
+	}
+
+	// A one-off transfer given a start a few seconds ago still runs
+	if err := planSchedule(&st, minTime(now, st.StartAt)); err != nil {
+		return st, err
+	}
+
+	err := ScanScheduledTransfer(db.QueryRow(
+		`INSERT INTO scheduled_transfers
+			(sender_id, receiver_id, amount, description, frequency, cron_expression, start_at, end_at, next_run_at, on_insufficient_funds, max_retries)
+		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+		 RETURNING `+ScheduledTransferColumns,
+		st.SenderID, st.ReceiverID, st.Amount, st.Description, st.Frequency, st.CronExpression,
+		st.StartAt, st.EndAt, st.NextRunAt, st.OnInsufficientFunds, st.MaxRetries,
+	), &st)
+	return st, err
+}
+
+// ListScheduledTransfers returns the sender's schedules, newest first
+func ListScheduledTransfers(db *sql.DB, senderID int) ([]models.ScheduledTransfer, error) {
+	rows, err := db.Query(
+		`SELECT `+ScheduledTransferColumns+`
+		 FROM scheduled_transfers
+		 WHERE sender_id = $1
+		 ORDER BY created_at DESC, id DESC`,
+		senderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.ScheduledTransfer{}
+	for rows.Next() {
+		var st models.ScheduledTransfer
+		if err := ScanScheduledTransfer(rows, &st); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, st)
+	}
+	return schedules, rows.Err()
+}
+
+// GetScheduledTransfer returns one of the sender's schedules together with its latest runs
+func GetScheduledTransfer(db *sql.DB, id, senderID int) (models.ScheduledTransfer, error) {
+	var st models.ScheduledTransfer
+	err := ScanScheduledTransfer(db.QueryRow(
+		"SELECT "+ScheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1 AND sender_id = $2",
+		id, senderID,
+	), &st)
+	if err == sql.ErrNoRows {
+		return st, ErrScheduleNotFound
+	}
+	if err != nil {
+		return st, err
+	}
+
+	rows, err := db.Query(
+		`SELECT id, scheduled_transfer_id, transaction_id, status, COALESCE(error, ''), scheduled_for, created_at
+		 FROM scheduled_transfer_runs
+		 WHERE scheduled_transfer_id = $1
+		 ORDER BY id DESC
+		 LIMIT 50`,
+		id,
+	)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+
+	st.Runs = []models.ScheduledTransferRun{}
+	for rows.Next() {
+		var run models.ScheduledTransferRun
+		if err := rows.Scan(&run.ID, &run.ScheduledTransferID, &run.TransactionID, &run.Status, &run.Error, &run.ScheduledFor, &run.CreatedAt); err != nil {
+			return st, err
+		}
+		st.Runs = append(st.Runs, run)
+	}
+	return st, rows.Err()
+}
+
+// lockScheduledTransfer locks one of the sender's schedules that can still be changed
+func lockScheduledTransfer(tx *sql.Tx, id, senderID int) (models.ScheduledTransfer, error) {
+	var st models.ScheduledTransfer
+	err := ScanScheduledTransfer(tx.QueryRow(
+		"SELECT "+ScheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1 AND sender_id = $2 FOR UPDATE",
+		id, senderID,
+	), &st)
+	if err == sql.ErrNoRows {
+		return st, ErrScheduleNotFound
+	}
+	if err != nil {
+		return st, err
+	}
+	if st.Status != ScheduleActive && st.Status != SchedulePaused {
+		return st, ErrScheduleFinished
+	}
+	return st, nil
+}
+
+// UpdateScheduledTransfer changes an active or paused schedule. The next run is planned again from now
+// and a pending retry is dropped.
+func UpdateScheduledTransfer(tx *sql.Tx, id, senderID int, req models.UpdateScheduledTransferRequest) (models.ScheduledTransfer, error) {
+	st, err := lockScheduledTransfer(tx, id, senderID)
+	if err != nil {
+		return st, err
+	}
+
+	if err = applyScheduledTransferUpdate(&st, req, time.Now().UTC()); err != nil {
+		return st, err
+	}
+
+	err = ScanScheduledTransfer(tx.QueryRow(
+		`UPDATE scheduled_transfers
+		 SET amount = $1, description = $2, frequency = $3, cron_expression = NULLIF($4, ''), start_at = $5, end_at = $6,
+			next_run_at = $7, on_insufficient_funds = $8, max_retries = $9, retry_count = 0, updated_at = NOW()
+		 WHERE id = $10
+		 RETURNING `+ScheduledTransferColumns,
+		st.Amount, st.Description, st.Frequency, st.CronExpression, st.StartAt, st.EndAt,
+		st.NextRunAt, st.OnInsufficientFunds, st.MaxRetries, st.ID,
+	), &st)
+	return st, err
+}
+
+// applyScheduledTransferUpdate changes the fields of st given in req and plans its next run
+func applyScheduledTransferUpdate(st *models.ScheduledTransfer, req models.UpdateScheduledTransferRequest, now time.Time) error {
+	if req.Amount != nil {
+		st.Amount = *req.Amount
+	}
+	if req.Description != nil {
+		st.Description = *req.Description
+	}
+	if req.Frequency != nil {
+		st.Frequency = *req.Frequency
+		// The expression only belongs to cron schedules
+		if st.Frequency != schedule.Cron && req.CronExpression == nil {
+			st.CronExpression = ""
+		}
+	}
+	if req.CronExpression != nil {
+		st.CronExpression = *req.CronExpression
+	}
+	if req.StartAt != nil {
+		if req.StartAt.Before(now.Add(-time.Minute)) {
+			return fmt.Errorf("%w: start_at must not be in the past", ErrInvalidSchedule)
+		}
+		st.StartAt = req.StartAt.UTC()
+	}
+	if req.EndAt != nil {
+		st.EndAt = req.EndAt
+	}
+	if req.OnInsufficientFunds != nil {
+		st.OnInsufficientFunds = *req.OnInsufficientFunds
+	}
+	if req.MaxRetries != nil {
+		st.MaxRetries = *req.MaxRetries
+	}
+
+	// The next run is planned from now, the tolerance for a start a moment ago only applies to a new start_at.
+	// Planning from an old start would make the next run a date that has already been paid.
+	from := now
+	if req.StartAt != nil {
+		from = minTime(now, st.StartAt)
+	}
+	return planSchedule(st, from)
+}
+
+// SetScheduledTransferStatus pauses, resumes or cancels a schedule. Resuming plans the next run from now,
+// occurrences missed while paused are not made up.
+func SetScheduledTransferStatus(tx *sql.Tx, id, senderID int, status string) (models.ScheduledTransfer, error) {
+	st, err := lockScheduledTransfer(tx, id, senderID)
+	if err != nil {
+		return st, err
+	}
+	if st.Status == status {
+		return st, ErrScheduleStatusUnchanged
+	}
+
+	nextRunAt := st.NextRunAt
+	switch status {
+	case ScheduleActive:
+		if nextRunAt, err = nextRun(st, time.Now().UTC()); err != nil {
+			return st, err
+		}
+		if nextRunAt == nil {
+			status = ScheduleCompleted
+		}
+	case ScheduleCancelled:
+		nextRunAt = nil
+	}
+
+	err = ScanScheduledTransfer(tx.QueryRow(
+		`UPDATE scheduled_transfers SET status = $1, next_run_at = $2, retry_count = 0, updated_at = NOW()
+		 WHERE id = $3
+		 RETURNING `+ScheduledTransferColumns,
+		status, nextRunAt, st.ID,
+	), &st)
+	return st, err
+}
+
+// RunDueScheduledTransfers executes every active schedule whose next run has come.
+// Each run happens in its own database transaction and schedules locked by an update are skipped.
+// A run that fails unexpectedly is recorded and retried later, the sweep carries on with the other schedules.
+func RunDueScheduledTransfers(db *sql.DB) (int, error) {
+	executed := 0
+	var failures []error
+	for {
+		// Pick the next due schedule without keeping it locked, its receiver is checked with the auth-service
+		// before the schedule is locked for the run
+		var due models.ScheduledTransfer
+		err := ScanScheduledTransfer(db.QueryRow(
+			`SELECT `+ScheduledTransferColumns+`
+			 FROM scheduled_transfers
+			 WHERE status = 'active' AND next_run_at <= NOW()
+			 ORDER BY next_run_at
+			 LIMIT 1
+			 FOR UPDATE SKIP LOCKED`,
+		), &due)
+		if err == sql.ErrNoRows {
+			return executed, errors.Join(failures...)
+		}
+		if err != nil {
+			return executed, errors.Join(append(failures, err)...)
+		}
+		_, receiverErr := ValidateReceiver(due.ReceiverID)
+
+		var ran bool
+		err = RunInTx(db, func(tx *sql.Tx) error {
+			// The schedule may have been run, paused or edited while the receiver was checked
+			var st models.ScheduledTransfer
+			err := ScanScheduledTransfer(tx.QueryRow(
+				`SELECT `+ScheduledTransferColumns+`
+				 FROM scheduled_transfers
+				 WHERE id = $1 AND status = 'active' AND next_run_at <= NOW()
+				 FOR UPDATE SKIP LOCKED`,
+				due.ID,
+			), &st)
+			if err == sql.ErrNoRows {
+				ran = false
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			ran = true
+			return runScheduledTransfer(tx, st, receiverErr)
+		})
+		if err != nil {
+			if deferErr := deferScheduledTransfer(db, due, err); deferErr != nil {
+				return executed, errors.Join(append(failures, err, deferErr)...)
+			}
+			failures = append(failures, fmt.Errorf("scheduled transfer %d: %w", due.ID, err))
+			continue
+		}
+		if ran {
+			executed++
+		}
+	}
+}
+
+// deferScheduledTransfer records a run that failed unexpectedly and moves the schedule back by the retry delay,
+// so it does not hold up the schedules behind it. It does not use up a retry, the sender is not at fault.
+func deferScheduledTransfer(db *sql.DB, st models.ScheduledTransfer, runErr error) error {
+	log.Printf("Scheduled transfer %d for %s failed unexpectedly: %v", st.ID, st.NextRunAt.Format(time.RFC3339), runErr)
+	retryAt := time.Now().UTC().Add(ScheduledTransferRetryDelay())
+
+	return RunInTx(db, func(tx *sql.Tx) error {
+		// Only a schedule that is still due is moved, another worker may have run it in the meantime
+		result, err := tx.Exec(
+			`UPDATE scheduled_transfers SET next_run_at = $1, updated_at = NOW()
+			 WHERE id = $2 AND status = 'active' AND next_run_at <= NOW()`,
+			retryAt, st.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if moved, err := result.RowsAffected(); err != nil || moved == 0 {
+			return err
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, status, error, scheduled_for)
+			 VALUES ($1, $2, $3, $4)`,
+			st.ID, RunRetrying, "unexpected error, the transfer will be tried again", *st.NextRunAt,
+		)
+		return err
+	})
+}
+
+// runOutcome sorts the result of a scheduled transfer attempt
+type runOutcome int
+
+const (
+	outcomeUnexpected  runOutcome = iota // an unexpected error, the run is rolled back and tried again after the retry delay
+	outcomeSucceeded                     // the transfer was made
+	outcomeCannotPay                     // the sender cannot pay this time, the skip or retry policy applies
+	outcomeUnavailable                   // a dependency was down, nobody's fault so it does not use up a retry
+	outcomePermanent                     // the transfer can never succeed, for example because the receiver is gone
+)
+
+func classifyRunError(err error) runOutcome {
+	var limitErr *LimitExceededError
+	switch {
+	case err == nil:
+		return outcomeSucceeded
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrWalletNotFound), errors.Is(err, ErrWalletFrozen), errors.As(err, &limitErr):
+		return outcomeCannotPay
+	case errors.Is(err, ErrUserLookupFailed):
+		return outcomeUnavailable
+	case errors.Is(err, ErrReceiverNotFound), errors.Is(err, ErrReceiverNotVerified), errors.Is(err, ErrReceiverDisabled),
+		errors.Is(err, ErrReceiverWalletClosed), errors.Is(err, ErrWalletClosed), errors.Is(err, ErrSelfTransfer):
+		return outcomePermanent
+	default:
+		return outcomeUnexpected
+	}
+}
+
+// runScheduledTransfer makes the transfer of one due occurrence through the same code path as POST /transfer,
+// records the run and plans the next one. Every outcome moves next_run_at forward or ends the schedule.
+// receiverErr is the result of checking the receiver before the schedule was locked.
+func runScheduledTransfer(tx *sql.Tx, st models.ScheduledTransfer, receiverErr error) error {
+	scheduledFor := *st.NextRunAt
+	now := time.Now().UTC()
+
+	description := st.Description
+	if description == "" {
+		description = "Scheduled transfer"
+	}
+
+	// The transfer runs in a savepoint so a failed attempt can be recorded in the same transaction
+	if _, err := tx.Exec("SAVEPOINT scheduled_transfer"); err != nil {
+		return err
+	}
+	transferErr := receiverErr
+	var transaction models.Transaction
+	if transferErr == nil {
+		transaction, transferErr = SendTransfer(tx, st.SenderID, st.ReceiverID, st.Amount, description, false)
+	}
+	outcome := classifyRunError(transferErr)
+	if outcome == outcomeUnexpected {
+		return transferErr
+	}
+	if transferErr != nil {
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT scheduled_transfer"); err != nil {
+			return err
+		}
+	}
+
+	// The regular occurrence after this one
+	upcoming, err := nextRun(st, latestTime(now, scheduledFor.Add(time.Nanosecond)))
+	if err != nil {
+		return err
+	}
+
+	run := models.ScheduledTransferRun{ScheduledTransferID: st.ID, Status: RunCompleted, ScheduledFor: scheduledFor}
+	status := ScheduleActive
+	nextRunAt := upcoming
+	retryCount := 0
+	retryAt := now.Add(ScheduledTransferRetryDelay())
+
+	switch outcome {
+	case outcomeSucceeded:
+		run.TransactionID = &transaction.ID
+	case outcomeUnavailable:
+		run.Status = RunRetrying
+		nextRunAt = &retryAt
+		retryCount = st.RetryCount
+	case outcomeCannotPay:
+		// A retry never runs past the next regular occurrence
+		if st.OnInsufficientFunds == PolicyRetry && st.RetryCount < st.MaxRetries && (upcoming == nil || retryAt.Before(*upcoming)) {
+			run.Status = RunRetrying
+			nextRunAt = &retryAt
+			retryCount = st.RetryCount + 1
+		} else {
+			run.Status = RunSkipped
+		}
+	case outcomePermanent:
+		run.Status = RunFailed
+		status = ScheduleFailed
+		nextRunAt = nil
+	}
+	if transferErr != nil {
+		run.Error = transferErr.Error()
+	}
+	if status == ScheduleActive && nextRunAt == nil {
+		status = ScheduleCompleted
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, transaction_id, status, error, scheduled_for)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5)`,
+		run.ScheduledTransferID, run.TransactionID, run.Status, run.Error, run.ScheduledFor,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`UPDATE scheduled_transfers SET status = $1, next_run_at = $2, retry_count = $3, updated_at = NOW()
+		 WHERE id = $4`,
+		status, nextRunAt, retryCount, st.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if transferErr != nil {
+		log.Printf("Scheduled transfer %d for %s %s: %v", st.ID, scheduledFor.Format(time.RFC3339), run.Status, transferErr)
+	}
+	return nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func latestTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
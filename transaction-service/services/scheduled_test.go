@@ -0,0 +1,72 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"transaction-service/models"
+	"transaction-service/schedule"
+)
+
+func TestApplyScheduledTransferUpdatePlansFromNow(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC)
+	amount := models.Money(2500)
+	tomorrow := now.Add(24 * time.Hour)
+	moment := now.Add(-30 * time.Second)
+
+	tests := []struct {
+		name string
+		st   models.ScheduledTransfer
+		req  models.UpdateScheduledTransferRequest
+		want time.Time
+	}{
+		{
+			name: "amount change keeps the daily time",
+			st:   models.ScheduledTransfer{Frequency: schedule.Daily, StartAt: start},
+			req:  models.UpdateScheduledTransferRequest{Amount: &amount},
+			want: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "monthly schedule started in the past",
+			st:   models.ScheduledTransfer{Frequency: schedule.Monthly, StartAt: start},
+			req:  models.UpdateScheduledTransferRequest{Amount: &amount},
+			want: time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "new start in the future",
+			st:   models.ScheduledTransfer{Frequency: schedule.Daily, StartAt: start},
+			req:  models.UpdateScheduledTransferRequest{StartAt: &tomorrow},
+			want: tomorrow,
+		},
+		{
+			name: "new start a moment ago",
+			st:   models.ScheduledTransfer{Frequency: schedule.Daily, StartAt: start},
+			req:  models.UpdateScheduledTransferRequest{StartAt: &moment},
+			want: moment,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.st
+			if err := applyScheduledTransferUpdate(&st, tt.req, now); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if st.NextRunAt == nil || !st.NextRunAt.Equal(tt.want) {
+				t.Fatalf("next run = %v, want %v", st.NextRunAt, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyScheduledTransferUpdateRejectsPastStart(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	st := models.ScheduledTransfer{Frequency: schedule.Daily, StartAt: now}
+
+	err := applyScheduledTransferUpdate(&st, models.UpdateScheduledTransferRequest{StartAt: &past}, now)
+	if !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("error = %v, want ErrInvalidSchedule", err)
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"transaction-service/models"
+)
+
+// How a bill is divided between its participants
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+)
+
+// Bill split statuses, a split is settled once every payment request of it is paid and incomplete
+// once none is left to pay but some were declined, cancelled or expired
+const (
+	BillSplitOpen       = "open"
+	BillSplitSettled    = "settled"
+	BillSplitIncomplete = "incomplete"
+	BillSplitCancelled  = "cancelled"
+)
+
+var (
+	ErrInvalidSplit      = errors.New("invalid bill split")
+	ErrBillSplitNotFound = errors.New("bill split not found")
+	ErrBillSplitNotOpen  = errors.New("bill split is not open")
+)
+
+// BillSplitColumns lists the bill_splits columns in the order ScanBillSplit reads them, the settled amount
+// is the creator's own share plus every paid payment request
+const BillSplitColumns = `id, creator_id, total_amount, description, split_type, creator_share, status,
+	creator_share + COALESCE((SELECT SUM(amount) FROM payment_requests WHERE split_id = bill_splits.id AND status = 'paid'), 0),
+	created_at, updated_at`
+
+// ScanBillSplit reads a row selected with BillSplitColumns
+func ScanBillSplit(row scanner, split *models.BillSplit) error {
+	return row.Scan(&split.ID, &split.CreatorID, &split.TotalAmount, &split.Description, &split.SplitType, &split.CreatorShare,
+		&split.Status, &split.SettledAmount, &split.CreatedAt, &split.UpdatedAt)
+}
+
+// AllocateShares divides total between the participants without losing a cent. Cents that do not divide
+// evenly go one each to the participants with the largest remainder, the earlier participant first on a tie,
+// so an equal split of 10.00 in three is 3.34, 3.33 and 3.33.
+func AllocateShares(total models.Money, splitType string, participants []models.BillSplitParticipant) ([]models.Money, error) {
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("%w: at least one participant is required", ErrInvalidSplit)
+	}
+
+	// Each participant's part of the whole, in basis points for percentages
+	weights := make([]int64, len(participants))
+	var whole int64
+	switch splitType {
+	case SplitEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+		whole = int64(len(participants))
+
+	case SplitExact:
+		shares := make([]models.Money, len(participants))
+		var sum models.Money
+		for i, p := range participants {
+			if p.Amount == nil {
+				return nil, fmt.Errorf("%w: participant %d needs an amount", ErrInvalidSplit, i+1)
+			}
+			shares[i] = *p.Amount
+			sum += *p.Amount
+		}
+		if sum != total {
+			return nil, fmt.Errorf("%w: the amounts add up to %s instead of %s", ErrInvalidSplit, sum, total)
+		}
+		return shares, nil
+
+	case SplitPercentage:
+		for i, p := range participants {
+			if p.Percentage == nil {
+				return nil, fmt.Errorf("%w: participant %d needs a percentage", ErrInvalidSplit, i+1)
+			}
+			basisPoints := math.Round(*p.Percentage * 100)
+			if math.Abs(*p.Percentage*100-basisPoints) > 1e-6 {
+				return nil, fmt.Errorf("%w: percentages may have at most two decimal places", ErrInvalidSplit)
+			}
+			weights[i] = int64(basisPoints)
+			whole += weights[i]
+		}
+		if whole != 10000 {
+			return nil, fmt.Errorf("%w: the percentages add up to %.2f instead of 100", ErrInvalidSplit, float64(whole)/100)
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: unknown split_type %q", ErrInvalidSplit, splitType)
+	}
+
+	// total * weight / whole, split up so large totals cannot overflow
+	quotient, remainder := int64(total)/whole, int64(total)%whole
+	shares := make([]models.Money, len(participants))
+	fractions := make([]int64, len(participants))
+	left := total
+	for i, weight := range weights {
+		shares[i] = models.Money(quotient*weight + remainder*weight/whole)
+		fractions[i] = remainder * weight % whole
+		left -= shares[i]
+	}
+
+	order := make([]int, len(participants))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return fractions[order[a]] > fractions[order[b]] })
+	for _, i := range order[:left] {
+		shares[i]++
+	}
+
+	for i, share := range shares {
+		if share <= 0 {
+			return nil, fmt.Errorf("%w: participant %d's share would be zero", ErrInvalidSplit, i+1)
+		}
+	}
+	return shares, nil
+}
+
+// ResolveSplitParticipants looks up every participant and returns their user ids in the same order.
+// The creator may be one of them, but at least one other user has to be.
+func ResolveSplitParticipants(creatorID int, participants []models.BillSplitParticipant) ([]int, error) {
+	userIDs := make([]int, len(participants))
+	seen := make(map[int]bool, len(participants))
+	others := 0
+	for i, p := range participants {
+		user, err := ResolvePayer(p.UserID, p.Email, p.Handle)
+		if errors.Is(err, ErrPayerRequired) {
+			return nil, fmt.Errorf("%w: participant %d needs exactly one of user_id, email or handle", ErrInvalidSplit, i+1)
+		}
+		if err != nil && !errors.Is(err, ErrUserLookupFailed) {
+			return nil, fmt.Errorf("%w: participant %d: %v", ErrInvalidSplit, i+1, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[user.ID] {
+			return nil, fmt.Errorf("%w: participant %d is listed more than once", ErrInvalidSplit, i+1)
+		}
+		seen[user.ID] = true
+		if user.ID != creatorID {
+			others++
+		}
+		userIDs[i] = user.ID
+	}
+
+	if others == 0 {
+		return nil, fmt.Errorf("%w: at least one participant other than yourself is required", ErrInvalidSplit)
+	}
+	return userIDs, nil
+}
+
+// CreateBillSplit records the split and sends every participant other than the creator a payment request
+// for their share. userIDs are the participants resolved by ResolveSplitParticipants.
+func CreateBillSplit(tx *sql.Tx, creatorID int, userIDs []int, req models.BillSplitRequest) (models.BillSplit, error) {
+	shares, err := AllocateShares(req.TotalAmount, req.SplitType, req.Participants)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	if err = checkRequesterWallet(tx, creatorID); err != nil {
+		return models.BillSplit{}, err
+	}
+
+	var creatorShare models.Money
+	for i, userID := range userIDs {
+		if userID == creatorID {
+			creatorShare = shares[i]
+		}
+	}
+
+	var splitID int
+	err = tx.QueryRow(
+		`INSERT INTO bill_splits (creator_id, total_amount, description, split_type, creator_share)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id`,
+		creatorID, req.TotalAmount, req.Description, req.SplitType, creatorShare,
+	).Scan(&splitID)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+
+	for i, userID := range userIDs {
+		if userID == creatorID {
+			continue
+		}
+		if _, err = insertPaymentRequest(tx, creatorID, userID, shares[i], req.Description, req.ExpiresInHours, &splitID); err != nil {
+			return models.BillSplit{}, err
+		}
+	}
+
+	return loadBillSplit(tx, splitID)
+}
+
+// ListBillSplits returns the splits the user created, newest first and without their shares
+func ListBillSplits(db *sql.DB, creatorID int) ([]models.BillSplit, error) {
+	rows, err := db.Query(
+		`SELECT `+BillSplitColumns+`
+		 FROM bill_splits
+		 WHERE creator_id = $1
+		 ORDER BY created_at DESC, id DESC`,
+		creatorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	splits := []models.BillSplit{}
+	for rows.Next() {
+		var split models.BillSplit
+		if err := ScanBillSplit(rows, &split); err != nil {
+			return nil, err
+		}
+		splits = append(splits, split)
+	}
+	return splits, rows.Err()
+}
+
+// GetBillSplit returns a split with every share to its creator and to its participants
+func GetBillSplit(db *sql.DB, id, userID int) (models.BillSplit, error) {
+	var visible bool
+	err := db.QueryRow(
+		`SELECT EXISTS (
+			SELECT 1 FROM bill_splits WHERE id = $1 AND creator_id = $2
+			UNION ALL
+			SELECT 1 FROM payment_requests WHERE split_id = $1 AND payer_id = $2
+		)`,
+		id, userID,
+	).Scan(&visible)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	if !visible {
+		return models.BillSplit{}, ErrBillSplitNotFound
+	}
+	return loadBillSplit(db, id)
+}
+
+// CancelBillSplit cancels the payment requests that are still pending, shares already paid stay paid
+func CancelBillSplit(tx *sql.Tx, id, creatorID int) (models.BillSplit, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM bill_splits WHERE id = $1 AND creator_id = $2 FOR UPDATE", id, creatorID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.BillSplit{}, ErrBillSplitNotFound
+	}
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	if status != BillSplitOpen {
+		return models.BillSplit{}, ErrBillSplitNotOpen
+	}
+
+	_, err = tx.Exec("UPDATE payment_requests SET status = 'cancelled', updated_at = NOW() WHERE split_id = $1 AND status = 'pending'", id)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	_, err = tx.Exec("UPDATE bill_splits SET status = 'cancelled', updated_at = NOW() WHERE id = $1", id)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	return loadBillSplit(tx, id)
+}
+
+// closeBillSplitsQuery ends the open splits that have no payment request left to pay. A pending request
+// past its expiry counts as expired even before the expiry sweep got to it.
+const closeBillSplitsQuery = `UPDATE bill_splits SET updated_at = NOW(), status = CASE
+		WHEN EXISTS (SELECT 1 FROM payment_requests WHERE split_id = bill_splits.id AND status <> 'paid') THEN 'incomplete'
+		ELSE 'settled'
+	END
+	WHERE status = 'open' AND NOT EXISTS (
+		SELECT 1 FROM payment_requests WHERE split_id = bill_splits.id AND status = 'pending' AND expires_at > NOW()
+	)`
+
+// closeBillSplitIfDone marks an open split as settled or incomplete once none of its payment requests is left to pay
+func closeBillSplitIfDone(tx *sql.Tx, splitID int) error {
+	// Lock the split first so the check below sees the requests answered by concurrent payers that got here earlier
+	var status string
+	if err := tx.QueryRow("SELECT status FROM bill_splits WHERE id = $1 FOR UPDATE", splitID).Scan(&status); err != nil {
+		return err
+	}
+	if status != BillSplitOpen {
+		return nil
+	}
+
+	_, err := tx.Exec(closeBillSplitsQuery+" AND id = $1", splitID)
+	return err
+}
+
+func loadBillSplit(q queryer, id int) (models.BillSplit, error) {
+	var split models.BillSplit
+	err := ScanBillSplit(q.QueryRow("SELECT "+BillSplitColumns+" FROM bill_splits WHERE id = $1", id), &split)
+	if err == sql.ErrNoRows {
+		return split, ErrBillSplitNotFound
+	}
+	if err != nil {
+		return split, err
+	}
+
+	split.Shares = []models.BillSplitShare{}
+	if split.CreatorShare > 0 {
+		split.Shares = append(split.Shares, models.BillSplitShare{UserID: split.CreatorID, Amount: split.CreatorShare, Status: PaymentRequestPaid})
+	}
+
+	rows, err := q.Query("SELECT "+PaymentRequestColumns+" FROM payment_requests WHERE split_id = $1 ORDER BY id", id)
+	if err != nil {
+		return split, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pr models.PaymentRequest
+		if err := ScanPaymentRequest(rows, &pr); err != nil {
+			return split, err
+		}
+		requestID := pr.ID
+		split.Shares = append(split.Shares, models.BillSplitShare{
+			UserID:           pr.PayerID,
+			Amount:           pr.Amount,
+			Status:           pr.Status,
+			PaymentRequestID: &requestID,
+			TransactionID:    pr.TransactionID,
+		})
+	}
+	return split, rows.Err()
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"transaction-service/models"
+)
+
+func equalParticipants(n int) []models.BillSplitParticipant {
+	return make([]models.BillSplitParticipant, n)
+}
+
+func percentageParticipants(percentages ...float64) []models.BillSplitParticipant {
+	participants := make([]models.BillSplitParticipant, len(percentages))
+	for i := range percentages {
+		participants[i].Percentage = &percentages[i]
+	}
+	return participants
+}
+
+func exactParticipants(amounts ...models.Money) []models.BillSplitParticipant {
+	participants := make([]models.BillSplitParticipant, len(amounts))
+	for i := range amounts {
+		participants[i].Amount = &amounts[i]
+	}
+	return participants
+}
+
+func TestAllocateShares(t *testing.T) {
+	tests := []struct {
+		name         string
+		total        models.Money
+		splitType    string
+		participants []models.BillSplitParticipant
+		want         []models.Money
+	}{
+		{"equal 10.00 in three", 1000, SplitEqual, equalParticipants(3), []models.Money{334, 333, 333}},
+		{"equal 0.05 in three gives the extra cents to the first two", 5, SplitEqual, equalParticipants(3), []models.Money{2, 2, 1}},
+		{"equal 10.01 in four", 1001, SplitEqual, equalParticipants(4), []models.Money{251, 250, 250, 250}},
+		{"exact", 1000, SplitExact, exactParticipants(250, 750), []models.Money{250, 750}},
+		{"percentage without rounding", 1000, SplitPercentage, percentageParticipants(33.33, 33.33, 33.34), []models.Money{333, 333, 334}},
+		{"percentage rounding goes to the largest remainder", 101, SplitPercentage, percentageParticipants(50, 25, 25), []models.Money{51, 25, 25}},
+		{"percentage rounding on a tie goes to the earlier participant", 5, SplitPercentage, percentageParticipants(25, 25, 25, 25), []models.Money{2, 1, 1, 1}},
+		{"large total", 999999999999999, SplitPercentage, percentageParticipants(12.5, 87.5), []models.Money{125000000000000, 874999999999999}},
+		{"large equal total", 999999999999999, SplitEqual, equalParticipants(7), []models.Money{142857142857143, 142857142857143, 142857142857143, 142857142857143, 142857142857143, 142857142857142, 142857142857142}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AllocateShares(tt.total, tt.splitType, tt.participants)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d shares, want %d", len(got), len(tt.want))
+			}
+			var sum models.Money
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("shares = %v, want %v", got, tt.want)
+				}
+				sum += got[i]
+			}
+			if sum != tt.total {
+				t.Fatalf("shares add up to %s instead of %s", sum, tt.total)
+			}
+		})
+	}
+}
+
+func TestAllocateSharesRejects(t *testing.T) {
+	tests := []struct {
+		name         string
+		total        models.Money
+		splitType    string
+		participants []models.BillSplitParticipant
+	}{
+		{"no participants", 1000, SplitEqual, nil},
+		{"share rounding to zero", 1, SplitEqual, equalParticipants(3)},
+		{"percentage share rounding to zero", 10, SplitPercentage, percentageParticipants(99.5, 0.5)},
+		{"exact amounts not adding up", 1000, SplitExact, exactParticipants(250, 700)},
+		{"exact amount missing", 1000, SplitExact, equalParticipants(2)},
+		{"percentages not adding up", 1000, SplitPercentage, percentageParticipants(50, 40)},
+		{"percentage with three decimals", 1000, SplitPercentage, percentageParticipants(33.333, 66.667)},
+		{"percentage missing", 1000, SplitPercentage, equalParticipants(2)},
+		{"unknown split type", 1000, "shares", equalParticipants(2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AllocateShares(tt.total, tt.splitType, tt.participants)
+			if !errors.Is(err, ErrInvalidSplit) {
+				t.Fatalf("error = %v, want ErrInvalidSplit", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"transaction-service/clients"
+	"transaction-service/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorTimeLayout matches the microsecond precision of PostgreSQL timestamps
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// TransactionColumns lists the transactions columns in the order ScanTransaction reads them
+const TransactionColumns = "id, sender_id, receiver_id, amount, status, description, transaction_type, expires_at, original_transaction_id, batch_id, created_at, updated_at"
+
+// scanner is satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ScanTransaction reads a row selected with TransactionColumns
+func ScanTransaction(row scanner, t *models.Transaction) error {
+	return row.Scan(
+		&t.ID, &t.SenderID, &t.ReceiverID, &t.Amount,
+		&t.Status, &t.Description, &t.TransactionType,
+		&t.ExpiresAt, &t.OriginalTransactionID, &t.BatchID, &t.CreatedAt, &t.UpdatedAt,
+	)
+}
+
+// insertTransaction records the draft in transactions and returns the row as stored
+func insertTransaction(tx *sql.Tx, draft models.Transaction) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := ScanTransaction(tx.QueryRow(
+		`INSERT INTO transactions (sender_id, receiver_id, amount, status, transaction_type, description, original_transaction_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+TransactionColumns,
+		draft.SenderID, draft.ReceiverID, draft.Amount, draft.Status, draft.TransactionType, draft.Description,
+		draft.OriginalTransactionID,
+	), &transaction)
+	return transaction, err
+}
+
+// ListTransactions returns one page of the user's transactions, newest first.
+// Pages are keyed on (created_at, id) so rows inserted while paging never shift the results.
+func ListTransactions(db *sql.DB, userID int, filter models.TransactionFilter) (models.TransactionPage, error) {
+	conditions := []string{"(sender_id = $1 OR receiver_id = $1)"}
+	args := []interface{}{userID}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.TransactionType != "" {
+		conditions = append(conditions, "transaction_type = "+addArg(filter.TransactionType))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+addArg(filter.Status))
+	}
+	if filter.CounterpartyID != 0 {
+		counterparty := addArg(filter.CounterpartyID)
+		conditions = append(conditions, fmt.Sprintf(
+			"((sender_id = $1 AND receiver_id = %[1]s) OR (receiver_id = $1 AND sender_id = %[1]s))", counterparty,
+		))
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount >= "+addArg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount <= "+addArg(*filter.MaxAmount))
+	}
+	// created_at holds the database's local time, so the bounds are converted to it rather than read as local times
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ("+addArg(filter.From.UTC().Format(time.RFC3339Nano))+"::timestamptz AT TIME ZONE current_setting('TimeZone'))")
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ("+addArg(filter.To.UTC().Format(time.RFC3339Nano))+"::timestamptz AT TIME ZONE current_setting('TimeZone'))")
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return models.TransactionPage{}, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < (%s::timestamp, %s)", addArg(createdAt), addArg(id)))
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	query := `SELECT ` + TransactionColumns + `
+		 FROM transactions
+		 WHERE ` + strings.Join(conditions, " AND ") + `
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT ` + addArg(filter.Limit+1)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return models.TransactionPage{}, err
+	}
+	defer rows.Close()
+
+	page := models.TransactionPage{Transactions: []models.Transaction{}}
+	for rows.Next() {
+		var t models.Transaction
+		if err := ScanTransaction(rows, &t); err != nil {
+			return models.TransactionPage{}, err
+		}
+		page.Transactions = append(page.Transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return models.TransactionPage{}, err
+	}
+
+	if len(page.Transactions) > filter.Limit {
+		page.Transactions = page.Transactions[:filter.Limit]
+		last := page.Transactions[len(page.Transactions)-1]
+		cursor := encodeCursor(last.CreatedAt, last.ID)
+		page.NextCursor = &cursor
+	}
+
+	return page, nil
+}
+
+// AddCounterparties names the other user of every transaction between two users. A user that cannot be looked up
+// is left out rather than failing the whole history.
+func AddCounterparties(userID int, transactions []models.Transaction) {
+	counterparties := make(map[int]*models.Counterparty)
+	for i := range transactions {
+		otherID := transactions[i].ReceiverID
+		if otherID == userID {
+			otherID = transactions[i].SenderID
+		}
+		if otherID == userID {
+			continue
+		}
+
+		counterparty, found := counterparties[otherID]
+		if !found {
+			user, err := clients.Users.GetUser(otherID)
+			if err == nil {
+				counterparty = &models.Counterparty{ID: user.ID, Name: user.Name, Handle: user.Handle}
+			} else if !errors.Is(err, clients.ErrUserNotFound) {
+				log.Printf("Failed to look up counterparty %d: %v", otherID, err)
+			}
+			counterparties[otherID] = counterparty
+		}
+		transactions[i].Counterparty = counterparty
+	}
+}
+
+// encodeCursor packs the sort key of the last row of a page into an opaque token
+func encodeCursor(createdAt time.Time, id int) string {
+	raw := createdAt.Format(cursorTimeLayout) + "|" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (string, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+
+	createdAt, idText, found := strings.Cut(string(raw), "|")
+	if !found {
+		return "", 0, ErrInvalidCursor
+	}
+	if _, err = time.Parse(cursorTimeLayout, createdAt); err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return createdAt, id, nil
+}
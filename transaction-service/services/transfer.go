@@ -0,0 +1,116 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"transaction-service/ledger"
+	"transaction-service/models"
+)
+
+var (
+	ErrSelfTransfer         = errors.New("cannot transfer to yourself")
+	ErrReceiverWalletClosed = errors.New("receiver wallet is closed")
+)
+
+// SendTransfer is the transfer behind POST /transfer: completed right away, or held as a pending transfer
+// until the receiver accepts it. The receiver must already have been validated.
+func SendTransfer(tx *sql.Tx, senderID, receiverID int, amount models.Money, description string, requireAcceptance bool) (models.Transaction, error) {
+	if requireAcceptance {
+		return CreatePendingTransfer(tx, senderID, receiverID, amount, description)
+	}
+	return Transfer(tx, senderID, receiverID, amount, description)
+}
+
+// Transfer moves amount from the sender's wallet to the receiver's wallet and records the transaction
+// together with its ledger entry. The receiver wallet is created if it does not exist yet.
+// Both wallets are locked in user_id order so that opposite transfers between the same users
+// wait for each other instead of deadlocking.
+func Transfer(tx *sql.Tx, senderID, receiverID int, amount models.Money, description string) (models.Transaction, error) {
+	// Limits are checked under the wallet locks so concurrent transfers cannot both use the last of a limit
+	if err := lockWallets(tx, senderID, receiverID); err != nil {
+		return models.Transaction{}, err
+	}
+	if err := CheckLimits(tx, senderID, amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return moveBetweenWallets(tx, models.Transaction{
+		SenderID:        senderID,
+		ReceiverID:      receiverID,
+		Amount:          amount,
+		Status:          "completed",
+		TransactionType: "transfer",
+		Description:     description,
+	})
+}
+
+// moveBetweenWallets is the wallet to wallet movement behind transfers and refunds
+func moveBetweenWallets(tx *sql.Tx, draft models.Transaction) (models.Transaction, error) {
+	if draft.SenderID == draft.ReceiverID {
+		return models.Transaction{}, ErrSelfTransfer
+	}
+
+	_, err := tx.Exec("INSERT INTO wallets (user_id, balance) VALUES ($1, 0.00) ON CONFLICT (user_id) DO NOTHING", draft.ReceiverID)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = lockWallets(tx, draft.SenderID, draft.ReceiverID); err != nil {
+		return models.Transaction{}, err
+	}
+
+	if _, err = DebitWallet(tx, draft.SenderID, draft.Amount); err != nil {
+		return models.Transaction{}, err
+	}
+
+	_, err = CreditWallet(tx, draft.ReceiverID, draft.Amount)
+	if errors.Is(err, ErrWalletClosed) {
+		return models.Transaction{}, ErrReceiverWalletClosed
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transaction, err := insertTransaction(tx, draft)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if err = postWalletToWallet(tx, transaction); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transaction, nil
+}
+
+// lockWallets takes the row locks of the given wallets in ascending user_id order.
+// Missing wallets are skipped, the following debit reports them.
+func lockWallets(tx *sql.Tx, userIDs ...int) error {
+	sorted := append([]int(nil), userIDs...)
+	sort.Ints(sorted)
+
+	for _, userID := range sorted {
+		var lockedID int
+		err := tx.QueryRow("SELECT user_id FROM wallets WHERE user_id = $1 FOR UPDATE", userID).Scan(&lockedID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return nil
+}
+
+// postWalletToWallet debits the sender wallet account and credits the receiver wallet account
+func postWalletToWallet(tx *sql.Tx, transaction models.Transaction) error {
+	senderAccountID, err := ledger.WalletAccount(tx, transaction.SenderID)
+	if err != nil {
+		return err
+	}
+	receiverAccountID, err := ledger.WalletAccount(tx, transaction.ReceiverID)
+	if err != nil {
+		return err
+	}
+	description := strings.ToUpper(transaction.TransactionType[:1]) + transaction.TransactionType[1:]
+	return ledger.Move(tx, transaction.ID, description, senderAccountID, receiverAccountID, transaction.Amount)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+	"transaction-service/config"
+
+	"github.com/lib/pq"
+)
+
+// ErrConcurrentUpdate is returned when PostgreSQL keeps aborting a transaction because of
+// deadlocks or serialization failures even after all retries
+var ErrConcurrentUpdate = errors.New("wallet is busy with another transaction, please retry")
+
+// PostgreSQL error codes that mean the transaction can safely be run again
+const (
+	pqSerializationFailure = "40001"
+	pqDeadlockDetected     = "40P01"
+)
+
+// RunInTx runs fn inside a database transaction and commits it.
+// If PostgreSQL aborts the transaction with a deadlock or serialization failure the whole
+// transaction is retried up to TX_MAX_ATTEMPTS times before ErrConcurrentUpdate is returned.
+func RunInTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	maxAttempts, err := strconv.Atoi(config.GetEnv("TX_MAX_ATTEMPTS", "3"))
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runOnce(db, fn)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt >= maxAttempts {
+			return fmt.Errorf("%w: %v", ErrConcurrentUpdate, err)
+		}
+
+		// Back off with jitter so the competing transactions do not collide again
+		backoff := time.Duration(attempt*20+rand.Intn(20)) * time.Millisecond
+		log.Printf("Retrying transaction after %v (attempt %d of %d): %v", backoff, attempt, maxAttempts, err)
+		time.Sleep(backoff)
+	}
+}
+
+func runOnce(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqSerializationFailure || pqErr.Code == pqDeadlockDetected
+}
@@ -0,0 +1,172 @@
+// Package services holds the wallet operations shared by the HTTP handlers.
+// Every function takes the caller's *sql.Tx so several operations commit or roll back together.
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"transaction-service/ledger"
+	"transaction-service/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrWalletFrozen      = errors.New("wallet is frozen")
+	ErrWalletClosed      = errors.New("wallet is closed")
+)
+
+// Wallet statuses. Frozen wallets can receive money but not send it, closed wallets can do neither.
+const (
+	WalletActive = "active"
+	WalletFrozen = "frozen"
+	WalletClosed = "closed"
+)
+
+// pqCheckViolation is the PostgreSQL error code raised by the wallets balance >= 0 constraint
+const pqCheckViolation = "23514"
+
+// WalletColumns lists the wallets columns in the order ScanWallet reads them
+const WalletColumns = "id, user_id, balance, status, tier, created_at, updated_at"
+
+// ScanWallet reads a row selected with WalletColumns
+func ScanWallet(row scanner, w *models.Wallet) error {
+	return row.Scan(&w.ID, &w.UserID, &w.Balance, &w.Status, &w.Tier, &w.CreatedAt, &w.UpdatedAt)
+}
+
+// DebitWallet takes amount out of an active wallet with a single conditional update.
+// The balance check and the deduction happen in the same statement under the row lock,
+// so concurrent debits can never drive the balance below zero.
+func DebitWallet(tx *sql.Tx, userID int, amount models.Money) (models.Money, error) {
+	return debitWallet(tx, userID, amount, false)
+}
+
+// debitWallet is DebitWallet, allowFrozen also takes money out of a frozen wallet for admin corrections
+func debitWallet(tx *sql.Tx, userID int, amount models.Money, allowFrozen bool) (models.Money, error) {
+	var newBalance models.Money
+	err := tx.QueryRow(
+		`UPDATE wallets SET balance = balance - $1, updated_at = NOW()
+		 WHERE user_id = $2 AND balance >= $1 AND (status = 'active' OR ($3 AND status = 'frozen'))
+		 RETURNING balance`,
+		amount, userID, allowFrozen,
+	).Scan(&newBalance)
+
+	if err == sql.ErrNoRows {
+		// Nothing was updated, find out whether the wallet is missing, not allowed to send or just short of funds
+		var status string
+		err = tx.QueryRow("SELECT status FROM wallets WHERE user_id = $1", userID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return 0, ErrWalletNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case status == WalletFrozen && !allowFrozen:
+			return 0, ErrWalletFrozen
+		case status == WalletClosed:
+			return 0, ErrWalletClosed
+		}
+		return 0, ErrInsufficientFunds
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqCheckViolation {
+		return 0, ErrInsufficientFunds
+	}
+
+	return newBalance, err
+}
+
+// CreditWallet adds amount to a wallet that is not closed, creating the wallet if the user does not have one yet
+func CreditWallet(tx *sql.Tx, userID int, amount models.Money) (models.Money, error) {
+	var newBalance models.Money
+	err := tx.QueryRow(
+		`INSERT INTO wallets (user_id, balance) VALUES ($1, $2)
+		 ON CONFLICT (user_id) DO UPDATE SET balance = wallets.balance + EXCLUDED.balance, updated_at = NOW()
+		 WHERE wallets.status <> 'closed'
+		 RETURNING balance`,
+		userID, amount,
+	).Scan(&newBalance)
+	if err == sql.ErrNoRows {
+		return 0, ErrWalletClosed
+	}
+	return newBalance, err
+}
+
+// Deposit credits the wallet with money coming from outside the platform and records the transaction
+func Deposit(tx *sql.Tx, userID int, amount models.Money) (models.Money, error) {
+	newBalance, err := CreditWallet(tx, userID, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	transaction, err := insertTransaction(tx, models.Transaction{
+		SenderID:        userID,
+		ReceiverID:      userID,
+		Amount:          amount,
+		Status:          "completed",
+		TransactionType: "deposit",
+		Description:     "Added funds to wallet",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fundingAccountID, err := ledger.SystemAccount(tx, ledger.ExternalFunding)
+	if err != nil {
+		return 0, err
+	}
+	walletAccountID, err := ledger.WalletAccount(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err = ledger.Move(tx, transaction.ID, "Deposit", fundingAccountID, walletAccountID, amount); err != nil {
+		return 0, err
+	}
+
+	return newBalance, nil
+}
+
+// Withdraw debits the wallet for money leaving the platform and records the transaction
+func Withdraw(tx *sql.Tx, userID int, amount models.Money) (models.Money, error) {
+	if err := lockWallets(tx, userID); err != nil {
+		return 0, err
+	}
+	if err := CheckLimits(tx, userID, amount); err != nil {
+		return 0, err
+	}
+
+	newBalance, err := DebitWallet(tx, userID, amount)
+	if err != nil {
+		return 0, err
+	}
+
+	transaction, err := insertTransaction(tx, models.Transaction{
+		SenderID:        userID,
+		ReceiverID:      userID,
+		Amount:          amount,
+		Status:          "completed",
+		TransactionType: "withdrawal",
+		Description:     "Withdrew funds from wallet",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	walletAccountID, err := ledger.WalletAccount(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	clearingAccountID, err := ledger.SystemAccount(tx, ledger.PayoutClearing)
+	if err != nil {
+		return 0, err
+	}
+	if err = ledger.Move(tx, transaction.ID, "Withdrawal", walletAccountID, clearingAccountID, amount); err != nil {
+		return 0, err
+	}
+
+	return newBalance, nil
+}
@@ -0,0 +1,69 @@
+// Package workers runs the background jobs of the transaction-service
+package workers
+
+import (
+	"log"
+	"strconv"
+	"time"
+	"transaction-service/config"
+	"transaction-service/services"
+)
+
+// every runs job right away and then once per interval for the lifetime of the process
+func every(name string, interval time.Duration, job func() error) {
+	log.Printf("Starting %s worker, running every %v", name, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(); err != nil {
+			log.Printf("%s worker failed: %v", name, err)
+		}
+		<-ticker.C
+	}
+}
+
+// intervalFromEnv reads a worker interval in seconds with a fallback for missing or invalid values
+func intervalFromEnv(key string, fallback int) time.Duration {
+	seconds, err := strconv.Atoi(config.GetEnv(key, strconv.Itoa(fallback)))
+	if err != nil || seconds < 1 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// StartPendingTransferExpiry returns unclaimed pending transfers to their senders in the background
+func StartPendingTransferExpiry() {
+	interval := intervalFromEnv("PENDING_TRANSFER_SWEEP_SECONDS", 60)
+	go every("pending transfer expiry", interval, func() error {
+		expired, err := services.ExpirePendingTransfers(config.DB)
+		if expired > 0 {
+			log.Printf("Expired %d pending transfers and returned the funds to their senders", expired)
+		}
+		return err
+	})
+}
+
+// StartScheduledTransfers executes due scheduled and recurring transfers in the background
+func StartScheduledTransfers() {
+	interval := intervalFromEnv("SCHEDULED_TRANSFER_POLL_SECONDS", 30)
+	go every("scheduled transfers", interval, func() error {
+		executed, err := services.RunDueScheduledTransfers(config.DB)
+		if executed > 0 {
+			log.Printf("Ran %d scheduled transfers", executed)
+		}
+		return err
+	})
+}
+
+// StartPaymentRequestExpiry marks unanswered payment requests as expired in the background
+func StartPaymentRequestExpiry() {
+	interval := intervalFromEnv("PAYMENT_REQUEST_SWEEP_SECONDS", 300)
+	go every("payment request expiry", interval, func() error {
+		expired, err := services.ExpirePaymentRequests(config.DB)
+		if expired > 0 {
+			log.Printf("Expired %d payment requests", expired)
+		}
+		return err
+	})
+}